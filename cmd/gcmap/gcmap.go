@@ -2,22 +2,40 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
 
 	"github.com/mrjones/oauth"
 	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/credentials"
+	"github.com/seanrees/tripist/internal/logging"
 	"github.com/seanrees/tripist/internal/tripit"
 )
 
 var (
-	startYear = flag.Int("start_year", 0, "Only consider trips after (incusive) of start_year.")
-	endYear   = flag.Int("end_year", 9999, "Only consider trips before (inclusive) of end_year.")
+	startYear   = flag.Int("start_year", 0, "Only consider trips after (incusive) of start_year.")
+	endYear     = flag.Int("end_year", 9999, "Only consider trips before (inclusive) of end_year.")
+	configFile  = flag.String("config", "", "tripist configuration file; defaults to $XDG_CONFIG_HOME/tripist/config.json.")
+	profile     = flag.String("profile", config.DefaultProfile, "tripist profile whose TripIt account to use.")
+	credStore   = flag.String("credentials", credentials.Plain, "How TripIt tokens are stored: plain or encrypted.")
+	credFile    = flag.String("credentials_file", "user.json", "File the TripIt tokens are stored in.")
+	credKeyFile = flag.String("credentials_key_file", "", "Key file for encrypted credentials; if empty, $TRIPIST_PASSPHRASE is used.")
+	logFormat   = flag.String("log_format", logging.Text, "Log format: text or json.")
+	logLevel    = flag.String("log_level", "info", "Log level: debug, info, warn or error.")
 )
 
+// fatal logs msg as an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
 type byStartDate []tripit.Segment
 
 func (a byStartDate) Len() int      { return len(a) }
@@ -65,36 +83,56 @@ func (a airportCounterSort) Less(i, j int) bool {
 }
 
 func main() {
-	const configFilename = "user.json"
-	const tripistConfigFilename = "tripist.json"
-
 	flag.Parse()
 
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	log.SetOutput(os.Stderr)
+	if err := logging.Setup(os.Stderr, *logFormat, *logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	var conf config.UserKeys
-	if _, err := os.Stat(configFilename); err == nil {
-		conf, err = config.Read(configFilename)
-		if err != nil {
-			log.Fatalf("Unable to read configuration: %v\n", err)
-		}
-	} else {
-		log.Fatalf("Configuration file %q does not exist.\n", configFilename)
+	conf, filename, err := config.Find(*configFile)
+	if err != nil {
+		fatal("Unable to load configuration", "file", filename, "error", err)
 	}
+	logging.Redact(conf.APIKeys.TripitAPISecret)
+	ps, err := conf.Profile(*profile)
+	if err != nil {
+		fatal("Unable to load profile", "profile", *profile, "error", err)
+	}
+	cc := ps.Credentials
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "credentials":
+			cc.Store = *credStore
+		case "credentials_file":
+			cc.File = *credFile
+		case "credentials_key_file":
+			cc.KeyFile = *credKeyFile
+		}
+	})
 
-	if err := config.LoadAPIKeys(tripistConfigFilename); err != nil {
-		log.Println("Using built-in keys.")
-	} else {
-		log.Printf("Loaded API keys from %s", tripistConfigFilename)
+	creds, err := credentials.New(credentials.Options{Kind: cc.Store, Filename: cc.File, KeyFile: cc.KeyFile})
+	if err != nil {
+		fatal("Unable to open credentials", "error", err)
+	}
+	keys, err := creds.Load()
+	if err != nil {
+		fatal("Unable to read credentials", "file", cc.File, "error", err)
+	}
+	if len(keys.TripitToken) == 0 {
+		fatal("No TripIt token; run tripist -authorize_tripit first", "file", cc.File, "env", credentials.TripitTokenEnv)
 	}
+	logging.Redact(keys.TripitToken, keys.TripitSecret)
 
 	token := &oauth.AccessToken{
-		Token:  conf.TripitToken,
-		Secret: conf.TripitSecret,
+		Token:  keys.TripitToken,
+		Secret: keys.TripitSecret,
 	}
 
-	api := tripit.NewTripitV1API(token)
+	api := tripit.NewTripitV1API(conf.APIKeys.Tripit(), token)
 	lp := tripit.ListParameters{
 		Traveler:       "true",
 		Past:           true,
@@ -107,20 +145,20 @@ func main() {
 
 	for {
 		lp.PageNum = page
-		tr, err := api.ListRaw(&lp)
+		tr, err := api.ListRaw(ctx, &lp)
 
 		if err != nil {
-			log.Printf("Got error: %v\n", err)
+			fatal("Unable to list trips", "page", page, "error", err)
 		}
 
-		log.Printf("Loaded %d (page=%d) trips\n", len(tr.Trip), page)
+		slog.Info("Loaded trips", "page", page, "trips", len(tr.Trip))
 		for i, a := range tr.Trip {
-			log.Printf("Trip %3d: %s\n", (page-1)*tr.PageSize+int64(i), a.DisplayName)
+			slog.Info("Trip", "n", (page-1)*tr.PageSize+int64(i), "trip_id", a.Id, "name", a.DisplayName)
 		}
 
 		for _, trip := range tr.Trip {
 			if sy, ey := trip.ActualStartDate.Year(), trip.ActualEndDate.Year(); sy < *startYear || ey > *endYear {
-				log.Printf("Ignoring %q because starts/ends in %d/%d, want in [%d-%d]", trip.DisplayName, sy, ey, *startYear, *endYear)
+				slog.Info("Ignoring trip outside years", "trip_id", trip.Id, "name", trip.DisplayName, "start_year", sy, "end_year", ey)
 				continue
 			}
 
@@ -164,10 +202,10 @@ func main() {
 		}
 	}
 
-	log.Printf("Top airports:\n")
+	fmt.Println("Top airports:")
 	for i, k := range airports.sortedKeys() {
-		log.Printf("  #%02d: %s (%d visits)\n", i, k, airports[k])
+		fmt.Printf("  #%02d: %s (%d visits)\n", i, k, airports[k])
 	}
 
-	log.Printf("http://www.gcmap.com/mapui?P=%s", strings.Join(paths, ","))
+	fmt.Printf("http://www.gcmap.com/mapui?P=%s\n", strings.Join(paths, ","))
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/overlap"
+	"github.com/seanrees/tripist/internal/rules"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// checklists chooses and loads the checklists for a profile's trips.
+type checklists struct {
+	s      config.Settings
+	rules  rules.ChecklistRules
+	loaded map[string][]tasks.ChecklistItem
+}
+
+// newChecklists reads the profile's checklist rules, if it has any.
+func newChecklists(s config.Settings) (*checklists, error) {
+	c := &checklists{s: s, loaded: make(map[string][]tasks.ChecklistItem)}
+	if len(s.ChecklistRules) > 0 {
+		r, err := rules.LoadChecklistRules(s.ChecklistRules)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load checklist rules: %v", err)
+		}
+		c.rules = r
+	}
+	return c, nil
+}
+
+// choose returns the checklists for t: its override's, those of the rules it
+// matches, or the profile's.
+func (c *checklists) choose(t tripit.Trip) rules.Choice {
+	if o := c.s.Trips.Checklist(t, ""); len(o) > 0 {
+		return rules.Choice{Checklists: []string{o}, Reason: "trip override"}
+	}
+	return c.rules.Choose(t, c.s.Checklist)
+}
+
+// load returns the checklist for ch, merging several into one (see
+// tasks.Merge). Each file is read once, when first chosen.
+func (c *checklists) load(ctx context.Context, ch rules.Choice) ([]tasks.ChecklistItem, error) {
+	var cls [][]tasks.ChecklistItem
+	for _, fn := range ch.Checklists {
+		cl, ok := c.loaded[fn]
+		if !ok {
+			var err error
+			if cl, err = tasks.Load(fn); err != nil {
+				return nil, fmt.Errorf("unable to load travel checklist (%s): %v", fn, err)
+			}
+			slog.InfoContext(ctx, "Loaded checklist", "file", fn, "items", len(cl))
+			c.loaded[fn] = cl
+		}
+		cls = append(cls, cl)
+	}
+	if len(cls) == 1 {
+		return cls[0], nil
+	}
+	return tasks.Merge(cls...), nil
+}
+
+// A tripChecklist is a trip to sync, perhaps several merged, and its
+// checklist.
+type tripChecklist struct {
+	trip      tripit.Trip
+	choice    rules.Choice
+	checklist []tasks.ChecklistItem
+	err       error
+}
+
+// forTrips chooses and loads the checklists for trips. Adjacent trips are
+// merged, or their checklists trimmed, as the profile says; merged trips
+// keep the ID of a trip with a project in links (see overlap.Group.Merged),
+// and the projects of the others are reported.
+func (c *checklists) forTrips(ctx context.Context, trips []tripit.Trip, links map[string]string) []tripChecklist {
+	adj := c.s.Trips.Adjacent
+	var ret []tripChecklist
+	for _, g := range overlap.Find(trips, adj.Gap()) {
+		if len(g) > 1 && len(adj.Mode) > 0 {
+			var ids []string
+			for _, t := range g {
+				ids = append(ids, t.Id)
+			}
+			slog.InfoContext(ctx, "Found adjacent trips", "trip_ids", ids, "mode", adj.Mode)
+		}
+
+		if len(g) > 1 && adj.Mode == overlap.Merge {
+			var cs []rules.Choice
+			for _, t := range g {
+				cs = append(cs, c.choose(t))
+			}
+			m, dropped := g.Merged(links)
+			for _, id := range dropped {
+				slog.WarnContext(ctx, "Merged trip's project is no longer synced; delete it or move its tasks", "trip_id", id, "project_id", links[id], "merged_trip_id", m.Id, "merged_project_id", links[m.Id])
+			}
+			ret = append(ret, c.forTrip(ctx, m, rules.Combine(cs...), false, false))
+			continue
+		}
+
+		for i, t := range g {
+			var pre, post bool
+			if adj.Mode == overlap.Suppress {
+				pre, post = g.Suppress(i)
+			}
+			ret = append(ret, c.forTrip(ctx, t, c.choose(t), pre, post))
+		}
+	}
+	return ret
+}
+
+// forTrip loads the checklist for t, trimming its pre-trip and post-trip
+// items if asked (see tasks.Trim).
+func (c *checklists) forTrip(ctx context.Context, t tripit.Trip, choice rules.Choice, pre, post bool) tripChecklist {
+	ctx = logging.With(ctx, "trip_id", t.Id)
+	slog.InfoContext(ctx, "Chose checklists", "trip", t.DisplayName, "checklists", choice.Checklists, "reason", choice.Reason)
+
+	ret := tripChecklist{trip: t, choice: choice}
+	ret.checklist, ret.err = c.load(ctx, choice)
+	if ret.err == nil && (pre || post) {
+		slog.InfoContext(ctx, "Suppressing items of adjacent trip", "trip", t.DisplayName, "pre_trip", pre, "post_trip", post)
+		ret.checklist = tasks.Trim(ret.checklist, pre, post)
+	}
+	return ret
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/overlap"
+	"github.com/seanrees/tripist/internal/rules"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+func TestForTripsDefaultChecklist(t *testing.T) {
+	dir := t.TempDir()
+	lisbon := filepath.Join(dir, "lisbon.csv")
+	if err := os.WriteFile(lisbon, []byte("Pack,1,1 day before start\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := config.Settings{Checklist: filepath.Join(dir, "missing.csv")}
+	s.Trips.Overrides = map[string]rules.Override{"T1": {Checklist: lisbon}}
+	c, err := newChecklists(s)
+	if err != nil {
+		t.Fatalf("newChecklists() == error (%v), want no error", err)
+	}
+
+	start := time.Date(2016, 7, 10, 0, 0, 0, 0, time.UTC)
+	t1 := tripit.Trip{Id: "T1", ActualStartDate: start, ActualEndDate: start.AddDate(0, 0, 3)}
+	t2 := tripit.Trip{Id: "T2", ActualStartDate: start.AddDate(0, 1, 0), ActualEndDate: start.AddDate(0, 1, 3)}
+
+	// The missing default does not matter to trips which don't use it.
+	got := c.forTrips(context.Background(), []tripit.Trip{t1}, nil)
+	if len(got) != 1 || got[0].err != nil || len(got[0].checklist) != 1 {
+		t.Errorf("forTrips(T1) == %+v, want lisbon.csv", got)
+	}
+
+	got = c.forTrips(context.Background(), []tripit.Trip{t1, t2}, nil)
+	if len(got) != 2 || got[0].err != nil || got[1].err == nil {
+		t.Errorf("forTrips(T1, T2) == %+v, want T2 failed", got)
+	}
+}
+
+func TestForTripsMergedProjects(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	var b bytes.Buffer
+	if err := logging.Setup(&b, logging.Text, "warn"); err != nil {
+		t.Fatalf("logging.Setup() == %v", err)
+	}
+
+	dir := t.TempDir()
+	cl := filepath.Join(dir, "checklist.csv")
+	if err := os.WriteFile(cl, []byte("Pack,1,1 day before start\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := config.Settings{Checklist: cl}
+	s.Trips.Adjacent.Mode = overlap.Merge
+	c, err := newChecklists(s)
+	if err != nil {
+		t.Fatalf("newChecklists() == error (%v), want no error", err)
+	}
+
+	start := time.Date(2016, 7, 10, 0, 0, 0, 0, time.UTC)
+	t1 := tripit.Trip{Id: "T1", ActualStartDate: start, ActualEndDate: start.AddDate(0, 0, 3)}
+	t2 := tripit.Trip{Id: "T2", ActualStartDate: start.AddDate(0, 0, 3), ActualEndDate: start.AddDate(0, 0, 6)}
+	links := map[string]string{"T1": "p1", "T2": "p2"}
+
+	got := c.forTrips(context.Background(), []tripit.Trip{t1, t2}, links)
+	if len(got) != 1 || got[0].trip.Id != "T1" {
+		t.Errorf("forTrips() == %+v, want T1 and T2 merged as T1", got)
+	}
+	if out := b.String(); !strings.Contains(out, "trip_id=T2 project_id=p2") {
+		t.Errorf("forTrips() logged %q, want T2's project p2 reported", out)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/preview"
+	"github.com/seanrees/tripist/internal/tasks"
+)
+
+// runPreview prints a trip's whole checklist, expanded, without writing to
+// Todoist. It returns the exit status.
+func runPreview(ctx context.Context, conf config.Config, name string, tf tripFlags, format string) int {
+	name, err := oneProfile(conf, name)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	s := settings(conf, name)
+
+	home, err := homeLocation(s)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	nameTmpl, err := tasks.ParseNameTemplate(s.Project.NameTemplate)
+	if err != nil {
+		slog.Error("Unable to parse project name template", "template", s.Project.NameTemplate, "error", err)
+		return exitUsage
+	}
+
+	trip, err := tf.find(ctx, conf.APIKeys, s)
+	if err != nil {
+		slog.Error("Unable to find trip", "error", err)
+		return exitFailure
+	}
+	if why := s.Trips.Skip(trip); len(why) > 0 {
+		slog.Warn("A sync would skip this trip", "trip_id", trip.Id, "reason", why)
+	}
+
+	cls, err := newChecklists(s)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitFailure
+	}
+	choice := cls.choose(trip)
+	checklist, err := cls.load(ctx, choice)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitFailure
+	}
+
+	project, err := tasks.ExpandName(nameTmpl, tripNameData(trip))
+	if err != nil {
+		slog.Error("Could not name project", "error", err)
+		return exitFailure
+	}
+
+	ctx = logging.With(ctx, "trip_id", trip.Id)
+	tt := taskTrip(ctx, trip)
+	p := preview.Preview{
+		Project:     project,
+		Trip:        trip,
+		Checklist:   choice,
+		Tasks:       tasks.ExpandAll(ctx, checklist, tt, home),
+		Home:        home,
+		Destination: tt.Destination,
+	}
+
+	if err := preview.Write(os.Stdout, format, p); err != nil {
+		slog.Error("Unable to write preview", "error", err)
+		return exitUsage
+	}
+	return exitOK
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/metrics"
+	"github.com/seanrees/tripist/internal/rules"
+	"github.com/seanrees/tripist/internal/schedule"
+)
+
+// serveOptions control runServe.
+type serveOptions struct {
+	policy schedule.Policy
+
+	// poll is how often to look for changes to the configuration and
+	// checklists.
+	poll time.Duration
+
+	// grace is how long shutdown waits for a sync in progress.
+	grace time.Duration
+
+	// metricsAddr, if set, is where to serve metrics and health.
+	metricsAddr string
+
+	// unhealthyAfter is how many syncs in a row must fail before the
+	// server reports itself unhealthy.
+	unhealthyAfter int
+}
+
+// check returns an error if o cannot be served, e.g. it would poll in a
+// busy loop.
+func (o serveOptions) check() error {
+	switch {
+	case o.policy.Interval <= 0:
+		return fmt.Errorf("-interval must be positive, not %v", o.policy.Interval)
+	case o.policy.Jitter < 0:
+		return fmt.Errorf("-jitter must not be negative, not %v", o.policy.Jitter)
+	case o.policy.MinRetry < 0:
+		return fmt.Errorf("-min_retry must not be negative, not %v", o.policy.MinRetry)
+	case o.poll <= 0:
+		return fmt.Errorf("-watch_interval must be positive, not %v", o.poll)
+	case o.grace < 0:
+		return fmt.Errorf("-shutdown_grace must not be negative, not %v", o.grace)
+	}
+	return nil
+}
+
+// runServe syncs the named profile, or all profiles, on a schedule until ctx
+// is cancelled. The configuration is reloaded, and a sync started, when it or
+// a checklist changes. It returns the exit status.
+func runServe(ctx context.Context, conf config.Config, filename, name string, o serveOptions) int {
+	if err := o.check(); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	names, err := profiles(conf, name)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	lockFile := lockPath(filename)
+
+	var m *metrics.Metrics
+	if len(o.metricsAddr) > 0 {
+		m = metrics.New()
+		srv := &http.Server{Addr: o.metricsAddr, Handler: m.Handler(o.unhealthyAfter)}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Metrics server failed", "error", err)
+			}
+		}()
+		defer srv.Close()
+		slog.Info("Serving metrics and health", "addr", o.metricsAddr)
+	}
+
+	// Syncs have their own context, so that shutdown can let one finish.
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+
+	w := schedule.NewWatcher(watchedFiles(conf, filename, names)...)
+	poll := time.NewTicker(o.poll)
+	defer poll.Stop()
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	failures := 0
+	next := time.NewTimer(0)
+	defer next.Stop()
+
+	slog.Info("Serving", "profiles", names, "interval", o.policy.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Shutting down")
+			return exitOK
+
+		case <-poll.C:
+			changed := w.Changed()
+			if len(changed) == 0 {
+				continue
+			}
+			slog.Info("Reloading configuration", "changed", changed)
+
+			c, _, err := config.Find(filename)
+			var n []string
+			if err == nil {
+				n, err = profiles(c, name)
+			}
+			if err != nil {
+				slog.Warn("Keeping previous configuration", "error", err)
+				continue
+			}
+			conf, names = c, n
+			redactKeys(conf.APIKeys)
+			w.Watch(watchedFiles(conf, filename, names)...)
+
+			if !next.Stop() {
+				select {
+				case <-next.C:
+				default:
+				}
+			}
+			next.Reset(0)
+
+		case <-next.C:
+			ok, stopped := syncScheduled(ctx, runCtx, cancelRuns, conf, lockFile, names, o.grace, m)
+			if stopped {
+				return exitOK
+			}
+			if ok {
+				failures = 0
+			} else {
+				failures++
+			}
+			d := o.policy.Next(failures, r)
+			slog.Info("Next sync scheduled", "in", d.Round(time.Second))
+			next.Reset(d)
+		}
+	}
+}
+
+// syncScheduled runs one sync in runCtx and reports whether it succeeded.
+// If ctx is cancelled meanwhile, it waits up to grace for the sync to finish
+// before cancelling it, and reports that the server is stopping.
+func syncScheduled(ctx, runCtx context.Context, cancelRun context.CancelFunc, conf config.Config, lockFile string, names []string, grace time.Duration, m *metrics.Metrics) (ok, stopped bool) {
+	done := make(chan bool, 1)
+	go func() {
+		summaries, err := syncAll(runCtx, conf, lockFile, names, m)
+		ok := err == nil && exitStatus(summaries) == exitOK
+		m.Sync(ok, time.Now())
+		if err != nil {
+			slog.Error("Sync failed", "error", err)
+		} else {
+			printSummary(os.Stdout, summaries)
+		}
+		done <- ok
+	}()
+
+	select {
+	case ok := <-done:
+		return ok, false
+
+	case <-ctx.Done():
+		slog.Info("Shutting down, waiting for the sync in progress", "grace", grace)
+		select {
+		case <-done:
+		case <-time.After(grace):
+			slog.Warn("Sync still running, cancelling it")
+			cancelRun()
+			<-done
+		}
+		return false, true
+	}
+}
+
+// watchedFiles returns the files whose changes reload the configuration:
+// the configuration itself, and each profile's checklist, checklist rules,
+// the checklists its rules choose and those of its trip overrides.
+func watchedFiles(conf config.Config, filename string, names []string) []string {
+	files := []string{filename}
+	seen := map[string]bool{filename: true}
+	add := func(fn string) {
+		if len(fn) > 0 && !seen[fn] {
+			files = append(files, fn)
+			seen[fn] = true
+		}
+	}
+
+	for _, n := range names {
+		s := settings(conf, n)
+		add(s.Checklist)
+
+		if len(s.ChecklistRules) > 0 {
+			add(s.ChecklistRules)
+			// Rules that cannot be read are reported when syncing; their
+			// file is still watched, so fixing it reloads them.
+			if r, err := rules.LoadChecklistRules(s.ChecklistRules); err == nil {
+				for _, cr := range r.Rules {
+					for _, cl := range cr.Checklists {
+						add(cl)
+					}
+				}
+			}
+		}
+
+		ids := make([]string, 0, len(s.Trips.Overrides))
+		for id := range s.Trips.Overrides {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			add(s.Trips.Overrides[id].Checklist)
+		}
+	}
+	return files
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/schedule"
+)
+
+func TestWatchedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		fn := filepath.Join(dir, name)
+		if err := os.WriteFile(fn, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return fn
+	}
+	write("rules.json", `{"Rules": [{"Purpose": ["B"], "Checklists": ["work.csv", "checklist.csv"]}]}`)
+	fn := write("config.json", `{"Checklist": "checklist.csv", "ChecklistRules": "rules.json",
+		"Trips": {"Overrides": {"T1": {"Checklist": "ski.csv"}, "T0": {"Checklist": "beach.csv"}, "T2": {"Skip": true}}}}`)
+
+	conf, err := config.Load(fn)
+	if err != nil {
+		t.Fatalf("config.Load() == %v", err)
+	}
+	want := []string{fn}
+	for _, n := range []string{"checklist.csv", "rules.json", "work.csv", "beach.csv", "ski.csv"} {
+		want = append(want, filepath.Join(dir, n))
+	}
+	if got := watchedFiles(conf, fn, []string{config.DefaultProfile}); !reflect.DeepEqual(got, want) {
+		t.Errorf("watchedFiles() == %v, want %v", got, want)
+	}
+
+	// Unreadable rules are still watched, so fixing them reloads.
+	write("rules.json", `{"Rules": `)
+	want = []string{fn}
+	for _, n := range []string{"checklist.csv", "rules.json", "beach.csv", "ski.csv"} {
+		want = append(want, filepath.Join(dir, n))
+	}
+	if got := watchedFiles(conf, fn, []string{config.DefaultProfile}); !reflect.DeepEqual(got, want) {
+		t.Errorf("watchedFiles(bad rules) == %v, want %v", got, want)
+	}
+}
+
+func TestServeOptionsCheck(t *testing.T) {
+	ok := serveOptions{policy: schedule.Policy{Interval: 24 * time.Hour, Jitter: 30 * time.Minute, MinRetry: 5 * time.Minute}, poll: 30 * time.Second, grace: 30 * time.Second}
+
+	cases := []struct {
+		name    string
+		mod     func(o *serveOptions)
+		wantErr bool
+	}{
+		{"defaults", func(o *serveOptions) {}, false},
+		{"no jitter", func(o *serveOptions) { o.policy.Jitter = 0 }, false},
+		{"no grace", func(o *serveOptions) { o.grace = 0 }, false},
+		{"zero interval", func(o *serveOptions) { o.policy.Interval = 0 }, true},
+		{"negative jitter", func(o *serveOptions) { o.policy.Jitter = -time.Minute }, true},
+		{"negative min_retry", func(o *serveOptions) { o.policy.MinRetry = -time.Minute }, true},
+		{"zero watch_interval", func(o *serveOptions) { o.poll = 0 }, true},
+		{"negative watch_interval", func(o *serveOptions) { o.poll = -time.Second }, true},
+		{"negative grace", func(o *serveOptions) { o.grace = -time.Second }, true},
+	}
+	for _, c := range cases {
+		o := ok
+		c.mod(&o)
+		if err := o.check(); (err != nil) != c.wantErr {
+			t.Errorf("check(%s) == %v, want error %v", c.name, err, c.wantErr)
+		}
+	}
+}
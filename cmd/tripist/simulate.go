@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/simulate"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// simulateOptions control runSimulate.
+type simulateOptions struct {
+	trip tripFlags
+
+	// daysBefore and daysAfter bound the simulation around the trip.
+	daysBefore, daysAfter int
+
+	// at is the time of day, in the home timezone, of each simulated sync.
+	at string
+}
+
+// runSimulate prints how the project for a trip would change over its
+// lifetime, syncing once a day, without writing to Todoist. It returns the
+// exit status.
+func runSimulate(ctx context.Context, conf config.Config, name string, o simulateOptions) int {
+	name, err := oneProfile(conf, name)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	s := settings(conf, name)
+
+	home, err := homeLocation(s)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	at, err := time.Parse("15:04", o.at)
+	if err != nil {
+		slog.Error("Invalid -at, want e.g; 09:00", "at", o.at)
+		return exitUsage
+	}
+
+	trip, err := o.trip.find(ctx, conf.APIKeys, s)
+	if err != nil {
+		slog.Error("Unable to find trip", "error", err)
+		return exitFailure
+	}
+	if why := s.Trips.Skip(trip); len(why) > 0 {
+		slog.Warn("A sync would skip this trip", "trip_id", trip.Id, "reason", why)
+	}
+
+	cls, err := newChecklists(s)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitFailure
+	}
+	choice := cls.choose(trip)
+	checklist, err := cls.load(ctx, choice)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitFailure
+	}
+
+	start, end := trip.ActualStartDate.In(home), trip.ActualEndDate.In(home)
+	from := time.Date(start.Year(), start.Month(), start.Day()-o.daysBefore, at.Hour(), at.Minute(), 0, 0, home)
+	to := time.Date(end.Year(), end.Month(), end.Day()+o.daysAfter, at.Hour(), at.Minute(), 0, 0, home)
+
+	ctx = logging.With(ctx, "trip_id", trip.Id)
+	steps := simulate.Run(ctx, checklist, taskTrip(ctx, trip), simulate.Options{
+		From: from,
+		To:   to,
+		Lead: time.Duration(s.TaskCutoffDays) * 24 * time.Hour,
+	})
+
+	fmt.Printf("Simulating %q (%s to %s) with %s\n", trip.DisplayName,
+		start.Format(tasks.DueFormat), end.Format(tasks.DueFormat), choice)
+	for _, st := range steps {
+		printStep(os.Stdout, st, trip, home)
+	}
+	return exitOK
+}
+
+// printStep writes what a simulated sync changed and, if anything did, the
+// project afterwards.
+func printStep(w io.Writer, st simulate.Step, trip tripit.Trip, loc *time.Location) {
+	fmt.Fprintf(w, "\n== %s (%s)\n", st.Now.In(loc).Format(tasks.DueFormat), tripDay(st.Now.In(loc), trip.ActualStartDate.In(loc), trip.ActualEndDate.In(loc)))
+	if len(st.Diffs) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	for _, d := range st.Diffs {
+		mark := "+"
+		if d.Type == tasks.Changed {
+			mark = "~"
+		}
+		fmt.Fprintf(w, "%s %s\n", mark, d.Task.Describe(loc))
+	}
+	fmt.Fprintln(w)
+	tasks.WriteTree(w, st.Tasks, loc)
+}
+
+// tripDay describes when now is relative to a trip, in calendar days.
+func tripDay(now, start, end time.Time) string {
+	days := func(a, b time.Time) int {
+		ad := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+		bd := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+		return int(bd.Sub(ad).Hours() / 24)
+	}
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", n)
+	}
+
+	switch {
+	case days(now, start) > 0:
+		return plural(days(now, start)) + " before start"
+	case days(end, now) > 0:
+		return plural(days(end, now)) + " after end"
+	}
+	return fmt.Sprintf("day %d of trip", days(start, now)+1)
+}
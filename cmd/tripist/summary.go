@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/seanrees/tripist/internal/reconcile"
+)
+
+// Exit statuses.
+const (
+	exitOK = 0
+
+	// exitFailure means nothing was synced.
+	exitFailure = 1
+
+	// exitUsage means the command line or configuration is wrong.
+	exitUsage = 2
+
+	// exitPartial means some trips or profiles synced, and some failed.
+	exitPartial = 3
+)
+
+// tripResult describes what happened to a trip.
+type tripResult struct {
+	Trip string
+	reconcile.Result
+}
+
+// profileSummary describes the sync of one profile.
+type profileSummary struct {
+	Profile string
+	Trips   []tripResult
+
+	// Err is set if the profile could not be synced, or not completely
+	// (e.g; it was interrupted).
+	Err error
+}
+
+// exitStatus returns exitOK if everything synced, exitFailure if nothing
+// did and exitPartial otherwise.
+func exitStatus(summaries []profileSummary) int {
+	var ok, failed int
+	for _, ps := range summaries {
+		for _, tr := range ps.Trips {
+			if tr.Err != nil {
+				failed++
+			} else {
+				ok++
+			}
+		}
+		if ps.Err != nil {
+			failed++
+		} else if len(ps.Trips) == 0 {
+			// Nothing to do is a success.
+			ok++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return exitOK
+	case ok == 0:
+		return exitFailure
+	}
+	return exitPartial
+}
+
+// printSummary writes a table of what happened to each trip.
+func printSummary(out io.Writer, summaries []profileSummary) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tTRIP\tACTION\tADDED\tCHANGED\tSKIPPED\tERROR")
+	for _, ps := range summaries {
+		for _, tr := range ps.Trips {
+			e := ""
+			if tr.Err != nil {
+				e = tr.Err.Error()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", ps.Profile, tr.Trip, tr.Action,
+				tr.Added, tr.Changed, tr.Skipped, e)
+		}
+		if ps.Err != nil {
+			fmt.Fprintf(w, "%s\t-\t%s\t\t\t\t%v\n", ps.Profile, reconcile.Failed, ps.Err)
+		}
+	}
+	w.Flush()
+}
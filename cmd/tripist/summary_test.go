@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/seanrees/tripist/internal/reconcile"
+)
+
+func TestExitStatus(t *testing.T) {
+	ok := tripResult{Trip: "Dublin", Result: reconcile.Result{Action: reconcile.Created}}
+	failed := tripResult{Trip: "Paris", Result: reconcile.Result{Action: reconcile.Failed, Err: errors.New("boom")}}
+	broken := errors.New("could not list trips")
+
+	cases := []struct {
+		summaries []profileSummary
+		want      int
+	}{
+		{nil, exitOK},
+		{[]profileSummary{{Profile: "alice"}}, exitOK},
+		{[]profileSummary{{Profile: "alice", Trips: []tripResult{ok, ok}}}, exitOK},
+		{[]profileSummary{{Profile: "alice", Trips: []tripResult{failed}}}, exitFailure},
+		{[]profileSummary{{Profile: "alice", Err: broken}}, exitFailure},
+		{[]profileSummary{{Profile: "alice", Trips: []tripResult{failed}}, {Profile: "bob", Err: broken}}, exitFailure},
+		{[]profileSummary{{Profile: "alice", Trips: []tripResult{ok, failed}}}, exitPartial},
+		{[]profileSummary{{Profile: "alice", Trips: []tripResult{ok}}, {Profile: "bob", Err: broken}}, exitPartial},
+		{[]profileSummary{{Profile: "alice"}, {Profile: "bob", Err: broken}}, exitPartial},
+		// An interrupted profile fails, though its trips synced.
+		{[]profileSummary{{Profile: "alice", Trips: []tripResult{ok}, Err: broken}}, exitPartial},
+	}
+
+	for i, c := range cases {
+		if got := exitStatus(c.summaries); got != c.want {
+			t.Errorf("%d: exitStatus(%+v) == %d, want %d", i, c.summaries, got, c.want)
+		}
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	cases := []struct {
+		summaries []profileSummary
+		want      string
+	}{{
+		want: "PROFILE  TRIP  ACTION  ADDED  CHANGED  SKIPPED  ERROR\n",
+	}, {
+		summaries: []profileSummary{{
+			Profile: "alice",
+			Trips: []tripResult{
+				{Trip: "Dublin, March", Result: reconcile.Result{Action: reconcile.Created, Added: 6, Skipped: 4}},
+				{Trip: "Tokyo, May", Result: reconcile.Result{Action: reconcile.Updated, Added: 1, Changed: 2}},
+				{Trip: "Paris, June", Result: reconcile.Result{Action: reconcile.Failed, Err: errors.New("unable to update project")}},
+			},
+		}, {
+			Profile: "bob",
+			Err:     errors.New("could not list trips"),
+		}},
+		want: `PROFILE  TRIP           ACTION   ADDED  CHANGED  SKIPPED  ERROR
+alice    Dublin, March  created  6      0        4
+alice    Tokyo, May     updated  1      2        0
+alice    Paris, June    failed   0      0        0        unable to update project
+bob      -              failed                            could not list trips
+`,
+	}}
+
+	for _, c := range cases {
+		var b bytes.Buffer
+		printSummary(&b, c.summaries)
+		// tabwriter pads empty ERROR cells.
+		if got := trimLines(b.String()); got != c.want {
+			t.Errorf("printSummary(%+v) ==\n%s\nwant\n%s", c.summaries, got, c.want)
+		}
+	}
+}
+
+// trimLines removes the trailing spaces from each line of s.
+func trimLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/lock"
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/metrics"
+	"github.com/seanrees/tripist/internal/reconcile"
+	"github.com/seanrees/tripist/internal/state"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/todoist"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// runSync syncs the named profile, or all profiles, and prints a summary.
+// It returns the exit status.
+func runSync(ctx context.Context, conf config.Config, lockFile, name string) int {
+	names, err := profiles(conf, name)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+
+	summaries, err := syncAll(ctx, conf, lockFile, names, nil)
+	if err != nil {
+		slog.Error("Sync failed", "error", err)
+		return exitFailure
+	}
+
+	printSummary(os.Stdout, summaries)
+	return exitStatus(summaries)
+}
+
+// syncAll syncs the named profiles while holding the lock in lockFile, so
+// that runs cannot overlap. A failing profile does not stop the others. The
+// sync is recorded in m, if set.
+func syncAll(ctx context.Context, conf config.Config, lockFile string, names []string, m *metrics.Metrics) ([]profileSummary, error) {
+	if err := os.MkdirAll(filepath.Dir(lockFile), 0700); err != nil {
+		return nil, err
+	}
+	l, err := lock.Acquire(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock %s: %v", lockFile, err)
+	}
+	defer l.Release()
+
+	var summaries []profileSummary
+	for _, n := range names {
+		if ctx.Err() != nil {
+			slog.WarnContext(ctx, "Interrupted, not syncing remaining profiles")
+			break
+		}
+		ctx := logging.With(ctx, "profile", n)
+		slog.InfoContext(ctx, "Syncing profile")
+		ps := syncProfile(ctx, conf.APIKeys, n, settings(conf, n), m)
+		if ps.Err != nil {
+			slog.ErrorContext(ctx, "Profile failed", "error", ps.Err)
+		}
+		summaries = append(summaries, ps)
+	}
+	return summaries, nil
+}
+
+// syncProfile creates and updates the projects for one profile's trips.
+func syncProfile(ctx context.Context, keys config.APIKeys, name string, s config.Settings, m *metrics.Metrics) profileSummary {
+	ps := profileSummary{Profile: name}
+
+	_, uk, err := openCredentials(s)
+	if err != nil {
+		ps.Err = err
+		return ps
+	}
+
+	checklists, err := newChecklists(s)
+	if err != nil {
+		ps.Err = err
+		return ps
+	}
+	nameTmpl, err := tasks.ParseNameTemplate(s.Project.NameTemplate)
+	if err != nil {
+		ps.Err = fmt.Errorf("unable to parse project name template %q: %v", s.Project.NameTemplate, err)
+		return ps
+	}
+
+	// Task deadlines are set in the home timezone.
+	home, err := homeLocation(s)
+	if err != nil {
+		ps.Err = err
+		return ps
+	}
+
+	// Profiles keep their files in their own directories.
+	for _, fn := range []string{s.StateFile, s.TodoistCache} {
+		if len(fn) == 0 {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			ps.Err = err
+			return ps
+		}
+	}
+
+	st, err := state.Read(s.StateFile)
+	if err != nil {
+		ps.Err = fmt.Errorf("unable to read state (%s): %v", s.StateFile, err)
+		return ps
+	}
+
+	trips, err := listTrips(ctx, keys.Tripit(), uk, s.Trips.Traveler, m.Transport("tripit", nil))
+	if err != nil {
+		ps.Err = fmt.Errorf("could not list trips: %v", err)
+		return ps
+	}
+	m.Trips(name, len(trips))
+
+	now := time.Now().In(home)
+	// Items without a lead time of their own use the cutoff.
+	lead := time.Duration(s.TaskCutoffDays) * 24 * time.Hour
+
+	slog.InfoContext(ctx, "Creating tasks due within their lead time", "trips", len(trips), "lead", lead)
+
+	// One client for all trips, so they share its reads.
+	todoapi, err := taskBackend(uk)
+	if err != nil {
+		ps.Err = fmt.Errorf("unable to create Todoist client: %v", err)
+		return ps
+	}
+	todoapi.SetTransport(m.Transport("todoist", nil))
+	todoapi.SetProjectOptions(todoist.ProjectOptions{Parent: s.Project.Parent, Color: s.Project.Color})
+	todoapi.SetLinks(st.Projects)
+	if len(s.TodoistCache) > 0 {
+		todoapi.SetCacheFile(s.TodoistCache)
+	}
+
+	var kept []tripit.Trip
+	for _, t := range trips {
+		if why := s.Trips.Skip(t); len(why) > 0 {
+			slog.InfoContext(logging.With(ctx, "trip_id", t.Id), "Skipping trip", "trip", t.DisplayName, "reason", why)
+			continue
+		}
+		kept = append(kept, t)
+	}
+
+	// Planning does no I/O, so only reconciling (which talks to Todoist)
+	// is spread across workers.
+	var plans []reconcile.Plan
+	var synced []tripit.Trip
+	for _, tc := range checklists.forTrips(ctx, kept, st.Projects) {
+		synced = append(synced, tc.trip)
+		if tc.err != nil {
+			plans = append(plans, reconcile.Plan{Project: tasks.Project{Id: tc.trip.Id}, Err: tc.err})
+			continue
+		}
+		plans = append(plans, planProject(ctx, tc.trip, tc.checklist, nameTmpl, now, lead))
+	}
+
+	for i, r := range reconcile.All(ctx, todoapi, plans, s.Workers) {
+		t := synced[i]
+		if r.Err != nil {
+			slog.ErrorContext(logging.With(ctx, "trip_id", t.Id), "Trip failed", "trip", t.DisplayName, "error", r.Err)
+		}
+		ps.Trips = append(ps.Trips, tripResult{Trip: t.DisplayName, Result: r})
+		m.Tasks(name, metrics.TasksCreated, r.Added)
+		m.Tasks(name, metrics.TasksUpdated, r.Changed)
+	}
+	if ctx.Err() != nil {
+		slog.WarnContext(ctx, "Interrupted, not all trips were processed")
+		ps.Err = ctx.Err()
+	}
+
+	if err := state.Write(st, s.StateFile); err != nil && ps.Err == nil {
+		ps.Err = fmt.Errorf("unable to write state (%s): %v", s.StateFile, err)
+	}
+	return ps
+}
+
+// listTrips lists the upcoming trips; traveler is as for config.Trips.
+func listTrips(ctx context.Context, k tripit.Keys, uc config.UserKeys, traveler string, rt http.RoundTripper) ([]tripit.Trip, error) {
+	api := tripit.NewTripitV1API(k, tripitOAuthAccessToken(uc))
+	api.SetTimeout(*httpTimeout)
+	api.SetTransport(rt)
+	return api.List(ctx, &tripit.ListParameters{Traveler: traveler, IncludeObjects: true})
+}
+
+// planProject expands the checklist into the project for trip.
+func planProject(ctx context.Context, trip tripit.Trip, cl []tasks.ChecklistItem, nameTmpl *template.Template, now time.Time, lead time.Duration) reconcile.Plan {
+	ctx = logging.With(ctx, "trip_id", trip.Id)
+	name, err := tasks.ExpandName(nameTmpl, tripNameData(trip))
+	if err != nil {
+		return reconcile.Plan{Project: tasks.Project{Id: trip.Id}, Err: fmt.Errorf("could not name project: %v", err)}
+	}
+
+	p := tasks.Project{
+		Name:  name,
+		Id:    trip.Id,
+		Tasks: tasks.Expand(ctx, cl, taskTrip(ctx, trip), now, lead)}
+	return reconcile.Plan{Project: p, Skipped: skipped(cl, p.Tasks)}
+}
+
+// skipped counts the checklist items with no task in ts. An item may expand
+// to several tasks (e.g; one per day), so ts may outnumber cl.
+func skipped(cl []tasks.ChecklistItem, ts []tasks.Task) int {
+	made := make(map[int]bool)
+	for _, t := range ts {
+		made[t.Position] = true
+	}
+	return len(cl) - len(made)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/seanrees/tripist/internal/tasks"
+)
+
+func TestSkipped(t *testing.T) {
+	cl := []tasks.ChecklistItem{{Template: "Pack"}, {Template: "Water plants"}, {Template: "Unpack"}}
+	task := func(pos int) tasks.Task { return tasks.Task{Position: pos} }
+
+	cases := []struct {
+		name string
+		ts   []tasks.Task
+		want int
+	}{
+		{"none", nil, 3},
+		{"all", []tasks.Task{task(0), task(1), task(2)}, 0},
+		{"some", []tasks.Task{task(2)}, 2},
+		// One item due every day of a week-long trip.
+		{"recurring", []tasks.Task{task(0), task(1), task(1), task(1), task(1), task(1), task(1), task(1)}, 1},
+	}
+	for _, c := range cases {
+		if got := skipped(cl, c.ts); got != c.want {
+			t.Errorf("skipped(%s) == %d, want %d", c.name, got, c.want)
+		}
+	}
+}
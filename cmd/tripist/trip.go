@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// tripFlags choose the trip for commands that act on one.
+type tripFlags struct {
+	trip, file, name, timezone *string
+}
+
+func addTripFlags(fs *flag.FlagSet) tripFlags {
+	return tripFlags{
+		trip:     fs.String("trip", "", "Trip: a TripIt trip ID, or its dates, e.g; 2016-07-15..2016-07-20 (or RFC 3339 times)."),
+		file:     fs.String("trip_file", "", "File holding a saved TripIt list response to find the trip in, rather than asking TripIt."),
+		name:     fs.String("name", "", "Trip name, when the trip is given by its dates."),
+		timezone: fs.String("timezone", "", "Destination timezone, e.g; Europe/Lisbon, when the trip is given by its dates."),
+	}
+}
+
+// find returns the chosen trip: from its dates, from a saved response, or by
+// ID from TripIt.
+func (f tripFlags) find(ctx context.Context, keys config.APIKeys, s config.Settings) (tripit.Trip, error) {
+	if start, end, ok := strings.Cut(*f.trip, ".."); ok {
+		return tripFromDates(start, end, *f.name, *f.timezone)
+	}
+
+	if len(*f.file) == 0 && len(*f.trip) == 0 {
+		return tripit.Trip{}, fmt.Errorf("-trip or -trip_file is required")
+	}
+	trips, err := loadTrips(ctx, keys, s, *f.file)
+	if err != nil {
+		return tripit.Trip{}, err
+	}
+	if len(*f.file) > 0 && len(*f.trip) == 0 && len(trips) == 1 {
+		return trips[0], nil
+	}
+
+	for _, t := range trips {
+		if t.Id == *f.trip {
+			return t, nil
+		}
+	}
+	if len(*f.trip) == 0 {
+		return tripit.Trip{}, fmt.Errorf("%s has %d trips; choose one with -trip", *f.file, len(trips))
+	}
+	if len(*f.file) > 0 {
+		return tripit.Trip{}, fmt.Errorf("%s has no trip %q", *f.file, *f.trip)
+	}
+	return tripit.Trip{}, fmt.Errorf("no upcoming trip %q", *f.trip)
+}
+
+// loadTrips returns the upcoming trips from file, a saved TripIt list
+// response, or from TripIt if file is empty.
+func loadTrips(ctx context.Context, keys config.APIKeys, s config.Settings, file string) ([]tripit.Trip, error) {
+	if len(file) > 0 {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		tr, err := tripit.ParseResponse(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %v", file, err)
+		}
+		return tr.Trip, nil
+	}
+
+	_, uk, err := openCredentials(s)
+	if err != nil {
+		return nil, err
+	}
+	trips, err := listTrips(ctx, keys.Tripit(), uk, s.Trips.Traveler, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not list trips: %v", err)
+	}
+	return trips, nil
+}
+
+// tripFromDates returns a trip called name, from start to end, in timezone tz.
+// Dates alone are taken as midnight UTC, as TripIt's are.
+func tripFromDates(start, end, name, tz string) (tripit.Trip, error) {
+	t := tripit.Trip{Id: "simulated", DisplayName: name, DestinationTimezone: tz}
+	if len(t.DisplayName) == 0 {
+		t.DisplayName = "Trip"
+	}
+
+	var err error
+	if t.ActualStartDate, err = parseTripTime(start); err != nil {
+		return t, err
+	}
+	if t.ActualEndDate, err = parseTripTime(end); err != nil {
+		return t, err
+	}
+	if t.ActualEndDate.Before(t.ActualStartDate) {
+		return t, fmt.Errorf("trip ends (%s) before it starts (%s)", end, start)
+	}
+	return t, nil
+}
+
+func parseTripTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("%q is neither a date (2006-01-02) nor an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// tripNameData returns the data project names are expanded with.
+func tripNameData(t tripit.Trip) tasks.NameData {
+	return tasks.NameData{
+		Name:     t.DisplayName,
+		Start:    t.ActualStartDate,
+		End:      t.ActualEndDate,
+		Location: t.PrimaryLocation,
+	}
+}
+
+// homeLocation returns the timezone deadlines are set in.
+func homeLocation(s config.Settings) (*time.Location, error) {
+	if len(s.HomeTimezone) == 0 {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(s.HomeTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load home timezone %q: %v", s.HomeTimezone, err)
+	}
+	return loc, nil
+}
+
+// destinationLocation returns the timezone of t's destination, or nil if it
+// is not known.
+func destinationLocation(t tripit.Trip) *time.Location {
+	if len(t.DestinationTimezone) == 0 {
+		return nil
+	}
+	loc, err := time.LoadLocation(t.DestinationTimezone)
+	if err != nil {
+		slog.Warn("Unable to load destination timezone", "trip_id", t.Id, "timezone", t.DestinationTimezone, "error", err)
+		return nil
+	}
+	return loc
+}
+
+// badLodging holds the IDs of lodging whose dates could not be parsed, so
+// each is only warned about once, not on every sync.
+var badLodging = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: make(map[string]bool)}
+
+// taskTrip returns what t's checklist is expanded for: its dates, its
+// destination and where it stays. Lodging without dates is logged (once)
+// and ignored.
+func taskTrip(ctx context.Context, t tripit.Trip) tasks.Trip {
+	ret := tasks.Trip{Start: t.ActualStartDate, End: t.ActualEndDate, Destination: destinationLocation(t)}
+	for _, l := range t.Lodging {
+		s := tasks.Stay{Name: l.SupplierName, Address: l.Address.Address, Confirmation: l.SupplierConfNum}
+		if len(s.Name) == 0 {
+			s.Name = l.DisplayName
+		}
+
+		var err error
+		if s.CheckIn, err = l.CheckIn(); err == nil {
+			s.CheckOut, err = l.CheckOut()
+		}
+		if err != nil {
+			badLodging.Lock()
+			warned := badLodging.ids[l.Id]
+			badLodging.ids[l.Id] = true
+			badLodging.Unlock()
+
+			level := slog.LevelWarn
+			if warned {
+				level = slog.LevelDebug
+			}
+			slog.Log(ctx, level, "Unable to parse lodging dates, ignoring it", "lodging", s.Name, "lodging_id", l.Id, "error", err)
+			continue
+		}
+		ret.Stays = append(ret.Stays, s)
+	}
+	return ret
+}
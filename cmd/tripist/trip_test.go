@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+func TestTaskTripBadLodging(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	var b bytes.Buffer
+	if err := logging.Setup(&b, logging.Text, "warn"); err != nil {
+		t.Fatalf("logging.Setup() == %v", err)
+	}
+
+	trip := tripit.Trip{Id: "T1", Lodging: []tripit.LodgingObject{{Id: "L1", DisplayName: "Hotel Lisboa"}}}
+	for i := 0; i < 3; i++ {
+		if got := taskTrip(context.Background(), trip); len(got.Stays) != 0 {
+			t.Errorf("taskTrip() stays == %v, want none", got.Stays)
+		}
+	}
+	if n := strings.Count(b.String(), "Unable to parse lodging dates"); n != 1 {
+		t.Errorf("taskTrip() warned %d times, want once:\n%s", n, b.String())
+	}
+}
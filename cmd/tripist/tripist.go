@@ -2,28 +2,68 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/mrjones/oauth"
 	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/credentials"
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/preview"
+	"github.com/seanrees/tripist/internal/schedule"
 	"github.com/seanrees/tripist/internal/tasks"
 	"github.com/seanrees/tripist/internal/todoist"
 	"github.com/seanrees/tripist/internal/tripit"
+	"github.com/seanrees/tripist/internal/trips"
 	"golang.org/x/oauth2"
 )
 
 var (
 	authorizeTripit  = flag.Bool("authorize_tripit", false, "Perform Tripit Authorization. This is an exclusive flag.")
 	authorizeTodoist = flag.Bool("authorize_todoist", false, "Perform Todoist Authorization. This is an exclusive flag.")
-	taskCutoffDays   = flag.Int("task_cutoff_days", 7, "Create tasks upto this many days in advance of their due date.")
+	configFile       = flag.String("config", "", "Configuration file; defaults to $XDG_CONFIG_HOME/tripist/config.json.")
+	profile          = flag.String("profile", "", "Profile to use; sync uses all profiles if empty.")
+	taskCutoffDays   = flag.Int("task_cutoff_days", 7, "Create tasks upto this many days in advance of their due date, unless their checklist item has a lead time.")
 	checklistCSV     = flag.String("checklist_csv", "checklist.csv", "Travel checklist CSV file.")
+	checklistRules   = flag.String("checklist_rules", "", "File of rules choosing other checklists for some trips.")
+	homeTimezone     = flag.String("home_timezone", "", "Timezone for task deadlines, e.g; Europe/Dublin; defaults to the system's.")
 	verifyTodoist    = flag.Bool("verify_todoist", false, "Perform Todoist API validation. This is an exclusive flag.")
+	projectParent    = flag.String("project_parent", "", "Create trip projects under this Todoist project (created if missing).")
+	projectColor     = flag.String("project_color", "", "Todoist colour for new trip projects, e.g; blue.")
+	projectName      = flag.String("project_name_template", tasks.DefaultNameTemplate, "Template for trip project names, e.g; {{.Name}} · {{dates .Start .End}}.")
+	stateFile        = flag.String("state_file", "state.json", "File linking trips to their Todoist projects.")
+	todoistCache     = flag.String("todoist_cache", "todoist-cache.json", "File to cache Todoist data in between runs; empty to disable.")
+	todoistAPI       = flag.String("todoist_api", todoist.BackendUnified, "Todoist API version to use: v1 (unified) or v9 (Sync v9, being retired).")
+	oauthPort        = flag.Int("oauth_port", 0, "Port for the local authorization callback server; 0 for any free port.")
+	oauthManual      = flag.Bool("oauth_manual", false, "Authorize by copying codes from a web page, rather than with a local callback server.")
+	httpTimeout      = flag.Duration("http_timeout", 60*time.Second, "Timeout for each request to TripIt and Todoist.")
+	credStore        = flag.String("credentials", credentials.Plain, "How to store TripIt and Todoist tokens: plain or encrypted.")
+	credFile         = flag.String("credentials_file", "user.json", "File to store TripIt and Todoist tokens in.")
+	credKeyFile      = flag.String("credentials_key_file", "", "Key file for encrypted credentials; if empty, $TRIPIST_PASSPHRASE is used.")
+	workers          = flag.Int("workers", config.DefaultWorkers, "Sync up to this many trips at once.")
+	logFormat        = flag.String("log_format", logging.Text, "Log format: text or json.")
+	logLevel         = flag.String("log_level", "info", "Log level: debug, info, warn or error; debug includes a summary of each API request.")
 )
 
+// fatal logs msg as an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(exitFailure)
+}
+
+// redactKeys keeps the application's secrets out of the logs.
+func redactKeys(k config.APIKeys) {
+	logging.Redact(k.TripitAPISecret, k.TodoistClientSecret)
+}
+
 func tripitOAuthAccessToken(u config.UserKeys) *oauth.AccessToken {
 	return &oauth.AccessToken{
 		Token:  u.TripitToken,
@@ -35,114 +75,274 @@ func todoistOAuth2Token(u config.UserKeys) *oauth2.Token {
 	return &oauth2.Token{AccessToken: u.TodoistToken}
 }
 
-func main() {
-	const configFilename = "user.json"
-	const tripistConfigFilename = "tripist.json"
+func taskBackend(u config.UserKeys) (todoist.TaskBackend, error) {
+	b, err := todoist.NewTaskBackend(*todoistAPI, todoistOAuth2Token(u))
+	if err != nil {
+		return nil, err
+	}
+	b.SetTimeout(*httpTimeout)
+	return b, nil
+}
 
-	flag.Parse()
+// fileFlags name the flags which set a profile's files; they can only be
+// used with a single profile.
+var fileFlags = map[string]bool{
+	"credentials_file": true,
+	"state_file":       true,
+	"todoist_cache":    true,
+}
 
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	log.SetOutput(os.Stderr)
+// applyFlags overrides settings with flags given on the command line.
+func applyFlags(s *config.Settings) {
+	overrides := map[string]func(){
+		"task_cutoff_days":      func() { s.TaskCutoffDays = *taskCutoffDays },
+		"checklist_csv":         func() { s.Checklist = *checklistCSV },
+		"checklist_rules":       func() { s.ChecklistRules = *checklistRules },
+		"home_timezone":         func() { s.HomeTimezone = *homeTimezone },
+		"project_parent":        func() { s.Project.Parent = *projectParent },
+		"project_color":         func() { s.Project.Color = *projectColor },
+		"project_name_template": func() { s.Project.NameTemplate = *projectName },
+		"state_file":            func() { s.StateFile = *stateFile },
+		"todoist_cache":         func() { s.TodoistCache = *todoistCache },
+		"credentials":           func() { s.Credentials.Store = *credStore },
+		"credentials_file":      func() { s.Credentials.File = *credFile },
+		"credentials_key_file":  func() { s.Credentials.KeyFile = *credKeyFile },
+		"workers":               func() { s.Workers = *workers },
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if o, ok := overrides[f.Name]; ok {
+			o()
+		}
+	})
+}
 
-	var conf config.UserKeys
-	if _, err := os.Stat(configFilename); err == nil {
-		conf, err = config.Read(configFilename)
-		if err != nil {
-			log.Fatalf("Unable to read configuration: %v\n", err)
+// profiles returns the names of the profiles to use: name, or all of them if
+// name is empty.
+func profiles(conf config.Config, name string) ([]string, error) {
+	if len(name) > 0 {
+		if _, err := conf.Profile(name); err != nil {
+			return nil, err
 		}
+		return []string{name}, nil
 	}
 
-	if err := config.LoadAPIKeys(tripistConfigFilename); err != nil {
-		log.Println("Using built-in keys.")
-	} else {
-		log.Printf("Loaded API keys from %s", tripistConfigFilename)
+	names := conf.ProfileNames()
+	if len(names) > 1 {
+		var err error
+		flag.Visit(func(f *flag.Flag) {
+			if fileFlags[f.Name] {
+				err = fmt.Errorf("-%s needs -profile when there are several profiles", f.Name)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		if envs := config.FileEnvs(); len(envs) > 0 {
+			return nil, fmt.Errorf("$%s needs -profile when there are several profiles", envs[0])
+		}
 	}
+	return names, nil
+}
 
-	if *authorizeTripit {
-		at := tripit.Authorize()
-		conf.TripitToken = at.Token
-		conf.TripitSecret = at.Secret
-		config.Write(conf, configFilename)
-		return
+// settings returns the named profile's settings, with flags applied.
+func settings(conf config.Config, name string) config.Settings {
+	s, err := conf.Profile(name)
+	if err != nil {
+		fatal("Unable to load profile", "profile", name, "error", err)
 	}
+	applyFlags(&s)
+	return s
+}
 
-	if *authorizeTodoist {
-		t := todoist.Authorize()
-		conf.TodoistToken = t.AccessToken
-		config.Write(conf, configFilename)
-		return
+// soleProfile returns the profile for commands that act on one profile.
+func soleProfile(conf config.Config) string {
+	name, err := oneProfile(conf, *profile)
+	if err != nil {
+		fatal(err.Error())
 	}
+	return name
+}
 
-	if *verifyTodoist {
-		api := todoist.NewSyncV9API(todoistOAuth2Token(conf))
-		if err := todoist.Verify(api); err != nil {
-			log.Printf("Todoist validation failed: %v", err)
-		} else {
-			log.Printf("Todoist validation success.")
-		}
-		return
+// oneProfile returns name, or the only profile if name is empty.
+func oneProfile(conf config.Config, name string) (string, error) {
+	names, err := profiles(conf, name)
+	if err != nil {
+		return "", err
 	}
+	if len(names) > 1 {
+		return "", fmt.Errorf("several profiles are configured (%s); choose one with -profile", strings.Join(names, ", "))
+	}
+	return names[0], nil
+}
 
-	checklist, err := tasks.Load(*checklistCSV)
+func openCredentials(s config.Settings) (credentials.Store, config.UserKeys, error) {
+	cc := s.Credentials
+	creds, err := credentials.New(credentials.Options{Kind: cc.Store, Filename: cc.File, KeyFile: cc.KeyFile})
 	if err != nil {
-		log.Fatalf("Unable to load travel checklist (%s): %v", *checklistCSV, err)
+		return nil, config.UserKeys{}, err
 	}
+	keys, err := creds.Load()
+	if err != nil {
+		return nil, keys, fmt.Errorf("unable to read credentials (%s): %v", cc.File, err)
+	}
+	logging.Redact(keys.TripitToken, keys.TripitSecret, keys.TodoistToken)
+	return creds, keys, nil
+}
 
-	log.Printf("Loaded %s with %d tasks\n", *checklistCSV, len(checklist))
-
-	trips := listTrips(conf)
-
-	window := time.Now().AddDate(0, 0, *taskCutoffDays)
+// lockPath returns the lock file that keeps syncs using the configuration
+// file from overlapping.
+func lockPath(configFile string) string {
+	return filepath.Join(filepath.Dir(configFile), "tripist.lock")
+}
 
-	log.Printf("Creating tasks up to cutoff %s", window)
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [sync|serve|simulate|preview|trips [command flags]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	for _, t := range trips {
-		createProject(conf, t, checklist, window)
+	if err := logging.Setup(os.Stderr, *logFormat, *logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
 	}
 
-}
+	// Ctrl-C (or SIGTERM) cancels requests in flight.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func listTrips(uc config.UserKeys) []tripit.Trip {
-	api := tripit.NewTripitV1API(tripitOAuthAccessToken(uc))
-	trips, err := api.List(&tripit.ListParameters{Traveler: "true", IncludeObjects: true})
+	conf, filename, err := config.Find(*configFile)
 	if err != nil {
-		log.Printf("Could not list trips: %v", err)
+		fatal("Unable to load configuration", "file", filename, "error", err)
 	}
+	redactKeys(conf.APIKeys)
+	slog.Info("Loaded configuration", "file", filename)
 
-	return trips
-}
-
-func createProject(uc config.UserKeys, trip tripit.Trip, cl []tasks.ChecklistItem, taskCutoff time.Time) {
-	// Fill this in from todoist.Authorize().
-	todoapi := todoist.NewSyncV9API(todoistOAuth2Token(uc))
+	if *authorizeTripit || *authorizeTodoist || *verifyTodoist {
+		name := soleProfile(conf)
+		s := settings(conf, name)
+		creds, keys, err := openCredentials(s)
+		if err != nil {
+			fatal(err.Error())
+		}
 
-	name := fmt.Sprintf("Trip: %s", trip.DisplayName)
-	log.Printf("Processing %s", name)
+		switch {
+		case *authorizeTripit:
+			at, err := tripit.Authorize(ctx, conf.APIKeys.Tripit(), tripit.AuthOptions{Port: *oauthPort, Manual: *oauthManual})
+			if err != nil {
+				fatal("TripIt authorization failed", "error", err)
+			}
+			keys.TripitToken = at.Token
+			keys.TripitSecret = at.Secret
+			if err := creds.Save(keys); err != nil {
+				fatal("Unable to save TripIt token", "error", err)
+			}
+			slog.Info("TripIt authorized, token saved", "profile", name, "file", s.Credentials.File)
 
-	p := tasks.Project{
-		Name:  name,
-		Tasks: tasks.Expand(cl, trip.ActualStartDate, trip.ActualEndDate, time.Now(), taskCutoff)}
+		case *authorizeTodoist:
+			t, err := todoist.Authorize(ctx, conf.APIKeys.Todoist(), todoist.AuthOptions{Port: *oauthPort, Manual: *oauthManual})
+			if err != nil {
+				fatal("Todoist authorization failed", "error", err)
+			}
+			keys.TodoistToken = t.AccessToken
+			if err := creds.Save(keys); err != nil {
+				fatal("Unable to save Todoist token", "error", err)
+			}
+			slog.Info("Todoist authorized, token saved", "profile", name, "file", s.Credentials.File)
 
-	if p.Empty() {
-		log.Println("No tasks within cutoff window, skipping.")
+		case *verifyTodoist:
+			b, err := taskBackend(keys)
+			if err != nil {
+				fatal("Unable to create Todoist client", "error", err)
+			}
+			if err := todoist.Verify(ctx, b); err != nil {
+				fatal("Todoist validation failed", "error", err)
+			}
+			slog.Info("Todoist validation success")
+		}
 		return
 	}
 
-	rp, found, err := todoapi.LoadProject(name)
-	if err != nil {
-		log.Printf("Could not load remote project: %v", err)
+	// Running without a command syncs, as tripist always has.
+	args := flag.Args()
+	cmd := "sync"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
 	}
-	if found {
-		diffs := rp.DiffTasks(p)
 
-		err = todoapi.UpdateProject(rp, diffs)
-		if err != nil {
-			log.Printf("Unable to update project: %v", err)
-		}
-	} else {
-		err = todoapi.CreateProject(p)
-		if err != nil {
-			log.Printf("Unable to create project: %v", err)
-		}
+	switch cmd {
+	case "sync":
+		fs := flag.NewFlagSet("sync", flag.ExitOnError)
+		name := fs.String("profile", *profile, "Profile to sync; all profiles if empty.")
+		fs.Parse(args)
+
+		os.Exit(runSync(ctx, conf, lockPath(filename), *name))
+
+	case "serve":
+		fs := flag.NewFlagSet("serve", flag.ExitOnError)
+		name := fs.String("profile", *profile, "Profile to sync; all profiles if empty.")
+		interval := fs.Duration("interval", 24*time.Hour, "Time between syncs.")
+		jitter := fs.Duration("jitter", 30*time.Minute, "Up to this much is added to each wait between syncs, at random.")
+		minRetry := fs.Duration("min_retry", 5*time.Minute, "Wait after a failed sync; doubled for each further failure, up to -interval.")
+		poll := fs.Duration("watch_interval", 30*time.Second, "How often to check the configuration and checklists for changes.")
+		grace := fs.Duration("shutdown_grace", 30*time.Second, "How long to let a sync in progress finish when shutting down.")
+		metricsAddr := fs.String("metrics_addr", "", "Address to serve Prometheus metrics (/metrics) and health (/healthz) on, e.g; :9100; empty to disable.")
+		unhealthyAfter := fs.Int("unhealthy_after", 3, "Report unhealthy after this many syncs in a row fail.")
+		fs.Parse(args)
+
+		os.Exit(runServe(ctx, conf, filename, *name, serveOptions{
+			policy:         schedule.Policy{Interval: *interval, Jitter: *jitter, MinRetry: *minRetry},
+			poll:           *poll,
+			grace:          *grace,
+			metricsAddr:    *metricsAddr,
+			unhealthyAfter: *unhealthyAfter,
+		}))
+
+	case "simulate":
+		fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+		name := fs.String("profile", *profile, "Profile whose settings and checklist to use.")
+		tf := addTripFlags(fs)
+		before := fs.Int("days_before", 42, "Start simulating this many days before the trip.")
+		after := fs.Int("days_after", 7, "Stop simulating this many days after the trip.")
+		at := fs.String("at", "09:00", "Time of day, in the home timezone, of each simulated sync.")
+		fs.Parse(args)
+
+		os.Exit(runSimulate(ctx, conf, *name, simulateOptions{trip: tf, daysBefore: *before, daysAfter: *after, at: *at}))
+
+	case "preview":
+		fs := flag.NewFlagSet("preview", flag.ExitOnError)
+		name := fs.String("profile", *profile, "Profile whose settings and checklist to use.")
+		tf := addTripFlags(fs)
+		format := fs.String("format", preview.Text, "Output format: text, markdown or json.")
+		fs.Parse(args)
+
+		os.Exit(runPreview(ctx, conf, *name, tf, *format))
+
+	case "trips":
+		fs := flag.NewFlagSet("trips", flag.ExitOnError)
+		name := fs.String("profile", *profile, "Profile whose trips to list.")
+		file := fs.String("trip_file", "", "File holding a saved TripIt list response to list, rather than asking TripIt.")
+		format := fs.String("format", trips.Table, "Output format: table or json.")
+		match := fs.String("match", "", "Only list trips whose names match this regular expression.")
+		purpose := fs.String("purpose", "", "Only list trips with this TripIt purpose code, e.g; B.")
+		from := fs.String("from", "", "Only list trips ending on or after this date (or RFC 3339 time).")
+		to := fs.String("to", "", "Only list trips starting on or before this date (or RFC 3339 time).")
+		projects := fs.Bool("projects", true, "Look up each trip's project in Todoist.")
+		fs.Parse(args)
+
+		os.Exit(runTrips(ctx, conf, *name, tripsOptions{
+			file:     *file,
+			format:   *format,
+			match:    *match,
+			purpose:  *purpose,
+			from:     *from,
+			to:       *to,
+			projects: *projects,
+		}))
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(exitUsage)
 	}
 }
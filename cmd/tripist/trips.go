@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/seanrees/tripist/internal/config"
+	"github.com/seanrees/tripist/internal/state"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/todoist"
+	"github.com/seanrees/tripist/internal/trips"
+)
+
+// tripsOptions control runTrips.
+type tripsOptions struct {
+	// file, if set, holds a saved TripIt list response to list instead.
+	file string
+
+	format string
+
+	// match, purpose, from and to filter the trips; see trips.Filter.
+	match, purpose, from, to string
+
+	// projects looks up each trip's project in Todoist.
+	projects bool
+}
+
+// runTrips lists the upcoming trips, as tripist sees them, and their
+// projects. It returns the exit status.
+func runTrips(ctx context.Context, conf config.Config, name string, o tripsOptions) int {
+	name, err := oneProfile(conf, name)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	s := settings(conf, name)
+
+	home, err := homeLocation(s)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	f, err := tripsFilter(o)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+
+	all, err := loadTrips(ctx, conf.APIKeys, s, o.file)
+	if err != nil {
+		slog.Error("Unable to list trips", "error", err)
+		return exitFailure
+	}
+
+	var es []trips.Entry
+	for _, t := range f.Apply(all) {
+		es = append(es, trips.Entry{Trip: t})
+	}
+	if o.projects {
+		if err := findProjects(ctx, s, es); err != nil {
+			slog.Error("Unable to find projects", "error", err)
+			return exitFailure
+		}
+	}
+
+	if err := trips.Write(os.Stdout, o.format, es, home); err != nil {
+		slog.Error("Unable to write trips", "error", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func tripsFilter(o tripsOptions) (trips.Filter, error) {
+	f := trips.Filter{Purpose: o.purpose}
+
+	var err error
+	if len(o.match) > 0 {
+		if f.Name, err = regexp.Compile(o.match); err != nil {
+			return f, fmt.Errorf("invalid -match %q: %v", o.match, err)
+		}
+	}
+	if len(o.from) > 0 {
+		if f.From, err = parseTripTime(o.from); err != nil {
+			return f, fmt.Errorf("invalid -from: %v", err)
+		}
+	}
+	if len(o.to) > 0 {
+		if f.To, err = parseTripTime(o.to); err != nil {
+			return f, fmt.Errorf("invalid -to: %v", err)
+		}
+		// A date alone includes trips starting that day.
+		if _, err := time.Parse(time.DateOnly, o.to); err == nil {
+			f.To = f.To.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	return f, nil
+}
+
+// findProjects looks up the project for each entry, as a sync would, without
+// changing anything.
+func findProjects(ctx context.Context, s config.Settings, es []trips.Entry) error {
+	nameTmpl, err := tasks.ParseNameTemplate(s.Project.NameTemplate)
+	if err != nil {
+		return fmt.Errorf("unable to parse project name template %q: %v", s.Project.NameTemplate, err)
+	}
+	st, err := state.Read(s.StateFile)
+	if err != nil {
+		return fmt.Errorf("unable to read state (%s): %v", s.StateFile, err)
+	}
+	_, uk, err := openCredentials(s)
+	if err != nil {
+		return err
+	}
+	todoapi, err := taskBackend(uk)
+	if err != nil {
+		return fmt.Errorf("unable to create Todoist client: %v", err)
+	}
+	// The state is not written back, so links found here are not kept.
+	todoapi.SetLinks(st.Projects)
+	if len(s.TodoistCache) > 0 {
+		todoapi.SetCacheFile(s.TodoistCache)
+	}
+
+	for i := range es {
+		t := es[i].Trip
+		name, err := tasks.ExpandName(nameTmpl, tripNameData(t))
+		if err != nil {
+			return fmt.Errorf("could not name project for trip %s: %v", t.Id, err)
+		}
+		p, found, err := todoapi.LoadProject(ctx, t.Id, name)
+		if err != nil {
+			return err
+		}
+		if found {
+			p.Name = todoist.ProjectName(p)
+			es[i].Project = trips.NewProject(p)
+		}
+	}
+	return nil
+}
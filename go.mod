@@ -1,20 +1,27 @@
 module github.com/seanrees/tripist
 
-go 1.20
+go 1.21
 
 require (
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/mrjones/oauth v0.0.0-20190623134757-126b35219450
+	github.com/prometheus/client_golang v1.9.0
 	github.com/twinj/uuid v1.0.0
-	golang.org/x/net v0.12.0 // indirect
+	golang.org/x/crypto v0.21.0
+	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/oauth2 v0.10.0
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
 )
 
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
 	github.com/myesui/uuid v1.0.0 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.15.0 // indirect
+	github.com/prometheus/procfs v0.2.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
 	gopkg.in/stretchr/testify.v1 v1.2.2 // indirect
 )
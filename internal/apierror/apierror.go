@@ -0,0 +1,67 @@
+// Package apierror describes failed requests to the TripIt and Todoist APIs.
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxExcerpt is the most of a response body kept in an HTTPError.
+const maxExcerpt = 512
+
+// HTTPError is returned when an API answers with a non-2xx status.
+type HTTPError struct {
+	// URL requested, without its query.
+	URL string
+
+	StatusCode int
+	Status     string
+
+	// Excerpt is the start of the response body, which usually says what
+	// went wrong.
+	Excerpt string
+}
+
+func (e *HTTPError) Error() string {
+	if len(e.Excerpt) == 0 {
+		return fmt.Sprintf("HTTP %s from %s", e.Status, e.URL)
+	}
+	return fmt.Sprintf("HTTP %s from %s: %s", e.Status, e.URL, e.Excerpt)
+}
+
+// Check returns an *HTTPError if resp does not have a 2xx status. body is the
+// response body, already read.
+func Check(resp *http.Response, body []byte) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	u := ""
+	if resp.Request != nil && resp.Request.URL != nil {
+		cu := *resp.Request.URL
+		cu.RawQuery = ""
+		u = cu.String()
+	}
+	return &HTTPError{
+		URL:        u,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Excerpt:    excerpt(body),
+	}
+}
+
+// excerpt returns the start of body on a single line.
+func excerpt(body []byte) string {
+	s := string(body)
+	if len(s) > maxExcerpt {
+		s = s[:maxExcerpt]
+		// Don't split a rune.
+		for len(s) > 0 && !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+		s += "…"
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
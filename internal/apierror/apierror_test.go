@@ -0,0 +1,63 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: "example.com", Path: "/sync", RawQuery: "token=secret"}}
+
+	cases := []struct {
+		code    int
+		status  string
+		body    string
+		wantErr bool
+		want    string
+	}{{
+		code:   200,
+		status: "200 OK",
+		body:   "{}",
+	}, {
+		code:    503,
+		status:  "503 Service Unavailable",
+		body:    "<html>\n  <body>Service Unavailable</body>\n</html>",
+		wantErr: true,
+		want:    "HTTP 503 Service Unavailable from https://example.com/sync: <html> <body>Service Unavailable</body> </html>",
+	}, {
+		code:    401,
+		status:  "401 Unauthorized",
+		wantErr: true,
+		want:    "HTTP 401 Unauthorized from https://example.com/sync",
+	}, {
+		code:    500,
+		status:  "500 Internal Server Error",
+		body:    strings.Repeat("x", 1000),
+		wantErr: true,
+		want:    "HTTP 500 Internal Server Error from https://example.com/sync: " + strings.Repeat("x", maxExcerpt) + "…",
+	}}
+
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.code, Status: c.status, Request: req}
+
+		err := Check(resp, []byte(c.body))
+		if (err != nil) != c.wantErr {
+			t.Errorf("Check(%d) == %v, want error %v", c.code, err, c.wantErr)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+
+		var he *HTTPError
+		if !errors.As(err, &he) || he.StatusCode != c.code {
+			t.Errorf("Check(%d) == %#v, want *HTTPError with that code", c.code, err)
+		}
+		if got := err.Error(); got != c.want {
+			t.Errorf("Check(%d).Error() == %q, want %q", c.code, got, c.want)
+		}
+	}
+}
@@ -3,14 +3,27 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
+	"github.com/seanrees/tripist/internal/overlap"
+	"github.com/seanrees/tripist/internal/rules"
+	"github.com/seanrees/tripist/internal/tasks"
 	"github.com/seanrees/tripist/internal/todoist"
 	"github.com/seanrees/tripist/internal/tripit"
 )
 
+// Version of the configuration file written by this programme.
+const Version = 1
+
+// Filename of the configuration file within Dir.
+const Filename = "config.json"
+
 type UserKeys struct {
 	// tripitToken is the user's TripIt oAuth token (oauth.AccessToken.Token).
 	TripitToken string
@@ -22,61 +35,328 @@ type UserKeys struct {
 	TodoistToken string
 }
 
-type apiKeys struct {
+// APIKeys identify tripist to TripIt and Todoist.
+type APIKeys struct {
 	TripitAPIKey        string
 	TripitAPISecret     string
 	TodoistClientID     string
 	TodoistClientSecret string
 }
 
-func Read(filename string) (UserKeys, error) {
-	uc := UserKeys{}
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return uc, err
+func (k APIKeys) Tripit() tripit.Keys {
+	return tripit.Keys{ConsumerKey: k.TripitAPIKey, ConsumerSecret: k.TripitAPISecret}
+}
+
+func (k APIKeys) Todoist() todoist.Keys {
+	return todoist.Keys{ClientID: k.TodoistClientID, ClientSecret: k.TodoistClientSecret}
+}
+
+// Credentials says where the user's tokens are kept (see package
+// credentials).
+type Credentials struct {
+	// Store is "plain" or "encrypted".
+	Store string
+
+	File string
+
+	// KeyFile holds the secret for an encrypted store; if empty, the
+	// passphrase is read from the environment.
+	KeyFile string `json:",omitempty"`
+}
+
+// Project controls how trip projects are made in Todoist.
+type Project struct {
+	// Parent project, created if missing.
+	Parent string `json:",omitempty"`
+
+	// Color of new projects, e.g; blue.
+	Color string `json:",omitempty"`
+
+	// NameTemplate for project names (see tasks.ParseNameTemplate).
+	NameTemplate string
+}
+
+// Trips chooses the trips to sync.
+type Trips struct {
+	// Traveler asks TripIt for the trips the user travels on ("true"), the
+	// trips they can see but do not travel on, e.g; colleagues' ("false"),
+	// or both ("all").
+	Traveler string
+
+	rules.Rules
+
+	Adjacent Adjacent
+}
+
+// Adjacent controls trips that overlap or run back to back.
+type Adjacent struct {
+	// Mode is overlap.Merge or overlap.Suppress; if empty, trips are synced
+	// on their own.
+	Mode string `json:",omitempty"`
+
+	// GapHours is how soon after one trip ends another must start to be
+	// adjacent to it.
+	GapHours float64 `json:",omitempty"`
+}
+
+// Gap returns GapHours as a time.Duration.
+func (a Adjacent) Gap() time.Duration {
+	return time.Duration(a.GapHours * float64(time.Hour))
+}
+
+// travelerValues are the valid values of Trips.Traveler.
+var travelerValues = map[string]bool{"true": true, "false": true, "all": true}
+
+// Settings are those that may differ between profiles.
+type Settings struct {
+	Credentials Credentials
+
+	// Checklist is the travel checklist CSV file.
+	Checklist string
+
+	// ChecklistRules, if set, is a file of rules choosing other checklists
+	// for some trips (see rules.ChecklistRules).
+	ChecklistRules string `json:",omitempty"`
+
+	// TaskCutoffDays is how many days in advance of their due date tasks
+	// are created, unless their checklist item has a lead time.
+	TaskCutoffDays int
+
+	// HomeTimezone is the IANA name of the timezone task deadlines are set
+	// in, e.g; Europe/Dublin. If empty, the system's timezone is used.
+	HomeTimezone string `json:",omitempty"`
+
+	Project Project
+
+	Trips Trips
+
+	// StateFile links trips to their Todoist projects.
+	StateFile string
+
+	// TodoistCache keeps Todoist data between runs; empty to disable.
+	TodoistCache string
+
+	// Workers is how many trips are synced at once.
+	Workers int
+}
+
+// resolve makes relative paths in s relative to dir.
+func (s *Settings) resolve(dir string) {
+	for _, p := range []*string{&s.Checklist, &s.ChecklistRules, &s.Credentials.File, &s.Credentials.KeyFile, &s.StateFile, &s.TodoistCache} {
+		if len(*p) > 0 && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	for id, o := range s.Trips.Overrides {
+		if len(o.Checklist) > 0 && !filepath.IsAbs(o.Checklist) {
+			o.Checklist = filepath.Join(dir, o.Checklist)
+			s.Trips.Overrides[id] = o
+		}
 	}
+}
 
-	err = json.Unmarshal(b, &uc)
-	return uc, err
+// check returns an error if s is invalid.
+func (s Settings) check() error {
+	if !travelerValues[s.Trips.Traveler] {
+		return fmt.Errorf("invalid Trips.Traveler %q (want true, false or all)", s.Trips.Traveler)
+	}
+	switch s.Trips.Adjacent.Mode {
+	case "", overlap.Merge, overlap.Suppress:
+	default:
+		return fmt.Errorf("invalid Trips.Adjacent.Mode %q (want %s or %s)", s.Trips.Adjacent.Mode, overlap.Merge, overlap.Suppress)
+	}
+	return nil
 }
 
-func Write(uc UserKeys, filename string) error {
-	b, err := json.MarshalIndent(uc, "", "\t")
-	if err != nil {
-		return err
+// DefaultProfile names the top-level Settings when there are no Profiles.
+const DefaultProfile = "default"
+
+// DefaultWorkers is how many trips are synced at once by default.
+const DefaultWorkers = 4
+
+type Config struct {
+	Version int
+
+	APIKeys APIKeys
+
+	// Settings of the DefaultProfile. Profiles inherit them, apart from
+	// their files (tokens, state and cache), which default to a directory
+	// named after the profile.
+	Settings
+
+	// Profiles for several users, by name.
+	Profiles map[string]Settings `json:",omitempty"`
+}
+
+// ProfileNames returns the names of the profiles, sorted, or just
+// DefaultProfile if there are none.
+func (c Config) ProfileNames() []string {
+	if len(c.Profiles) == 0 {
+		return []string{DefaultProfile}
+	}
+	var names []string
+	for n := range c.Profiles {
+		names = append(names, n)
 	}
-	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0700)
-	defer f.Close()
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the settings for the named profile.
+func (c Config) Profile(name string) (Settings, error) {
+	if len(c.Profiles) == 0 && name == DefaultProfile {
+		return c.Settings, nil
+	}
+	s, ok := c.Profiles[name]
+	if !ok {
+		return s, fmt.Errorf("no profile %q", name)
+	}
+	return s, nil
+}
+
+// Default returns the configuration used for settings missing from the
+// file.
+func Default() Config {
+	return Config{
+		Version: Version,
+		Settings: Settings{
+			Credentials:    Credentials{Store: "plain", File: "user.json"},
+			Checklist:      "checklist.csv",
+			TaskCutoffDays: 7,
+			Project:        Project{NameTemplate: tasks.DefaultNameTemplate},
+			Trips:          Trips{Traveler: "true"},
+			StateFile:      "state.json",
+			TodoistCache:   "todoist-cache.json",
+			Workers:        DefaultWorkers,
+		},
+	}
+}
+
+// Dir returns the directory holding tripist's configuration:
+// $XDG_CONFIG_HOME/tripist, or ~/.config/tripist.
+func Dir() (string, error) {
+	if d := os.Getenv("XDG_CONFIG_HOME"); len(d) > 0 {
+		return filepath.Join(d, "tripist"), nil
+	}
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return err
+		return "", err
 	}
-	n, err := f.Write(b)
+	return filepath.Join(home, ".config", "tripist"), nil
+}
+
+// DefaultPath returns the path of the configuration file in Dir.
+func DefaultPath() (string, error) {
+	d, err := Dir()
 	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, Filename), nil
+}
+
+// Load reads the configuration from filename. Settings missing from the file
+// take their Default; a missing file yields the defaults. Relative paths in
+// the configuration are relative to the file's directory.
+func Load(filename string) (Config, error) {
+	c := Default()
+	b, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return c, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &c); err != nil {
+			return c, fmt.Errorf("%s: %v", filename, err)
+		}
+		if err := c.inheritProfiles(b); err != nil {
+			return c, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	if c.Version > Version {
+		return c, fmt.Errorf("%s: version %d is newer than this programme supports (%d)", filename, c.Version, Version)
+	}
+	c.Version = Version
+
+	if err := c.Settings.check(); err != nil {
+		return c, fmt.Errorf("%s: %v", filename, err)
+	}
+	for n, p := range c.Profiles {
+		if err := p.check(); err != nil {
+			return c, fmt.Errorf("%s: profile %q: %v", filename, n, err)
+		}
+	}
+
+	dir := filepath.Dir(filename)
+	c.Settings.resolve(dir)
+	for n, p := range c.Profiles {
+		p.resolve(dir)
+		c.Profiles[n] = p
+	}
+	return c, nil
+}
+
+// inheritProfiles re-reads the profiles in b on top of the top-level
+// settings, so a profile need only give what differs.
+func (c *Config) inheritProfiles(b []byte) error {
+	var raw struct {
+		Profiles map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
 		return err
 	}
-	if l := len(b); l != n {
-		return fmt.Errorf("short write to %s, wrote %d of %d bytes", filename, n, l)
+
+	for n, r := range raw.Profiles {
+		// Names are used as directory names.
+		if len(n) == 0 || n == DefaultProfile || n == "." || n == ".." || filepath.Base(n) != n {
+			return fmt.Errorf("invalid profile name %q", n)
+		}
+		p := c.Settings
+		p.Credentials.File = filepath.Join(n, filepath.Base(Default().Credentials.File))
+		p.StateFile = filepath.Join(n, filepath.Base(Default().StateFile))
+		if len(p.TodoistCache) > 0 {
+			p.TodoistCache = filepath.Join(n, filepath.Base(Default().TodoistCache))
+		}
+		// Don't let the profile's rules change the top-level ones.
+		p.Trips.Rules = p.Trips.Rules.Clone()
+		if err := json.Unmarshal(r, &p); err != nil {
+			return fmt.Errorf("profile %q: %v", n, err)
+		}
+		c.Profiles[n] = p
 	}
 	return nil
 }
 
-func LoadAPIKeys(filename string) error {
-	b, err := ioutil.ReadFile(filename)
+// Save writes the configuration to filename, creating its directory if
+// needed. The file holds API secrets, so only the user may read it.
+func Save(c Config, filename string) error {
+	b, err := json.MarshalIndent(c, "", "\t")
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	return writePrivate(filename, b)
+}
 
-	tc := &apiKeys{}
-	err = json.Unmarshal(b, &tc)
+// writePrivate atomically replaces filename with b, readable only by the
+// user.
+func writePrivate(filename string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(f.Name())
 
-	// Copy in parameters.
-	tripit.ConsumerKey = tc.TripitAPIKey
-	tripit.ConsumerSecret = tc.TripitAPISecret
-	todoist.Oauth2ClientID = tc.TodoistClientID
-	todoist.Oauth2ClientSecret = tc.TodoistClientSecret
-
-	return nil
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
 }
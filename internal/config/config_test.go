@@ -0,0 +1,283 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/seanrees/tripist/internal/rules"
+)
+
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []struct {
+		content string
+		want    func(*Config)
+		wantErr bool
+	}{
+		{
+			// Missing file.
+			want: func(c *Config) {},
+		},
+		{
+			content: `{"Version": 1, "Checklist": "/abs/list.csv", "TaskCutoffDays": 3, "Credentials": {"File": "tokens.json"}}`,
+			want: func(c *Config) {
+				c.Checklist = "/abs/list.csv"
+				c.TaskCutoffDays = 3
+				c.Credentials.File = filepath.Join(dir, "tokens.json")
+			},
+		},
+		{
+			content: `{"Trips": {"Traveler": "all", "Exclude": [{"Private": true}], "Overrides": {"T0": {"Checklist": "ski.csv"}}}}`,
+			want: func(c *Config) {
+				private := true
+				c.Trips.Traveler = "all"
+				c.Trips.Exclude = []rules.Match{{Private: &private}}
+				c.Trips.Overrides = map[string]rules.Override{"T0": {Checklist: filepath.Join(dir, "ski.csv")}}
+			},
+		},
+		{
+			content: `{"Trips": {"Traveler": "maybe"}}`,
+			wantErr: true,
+		},
+		{
+			content: `{"Trips": {"Adjacent": {"Mode": "merge", "GapHours": 36}}}`,
+			want: func(c *Config) {
+				c.Trips.Adjacent = Adjacent{Mode: "merge", GapHours: 36}
+			},
+		},
+		{
+			content: `{"Trips": {"Adjacent": {"Mode": "both"}}}`,
+			wantErr: true,
+		},
+		{
+			content: `{"Version": 2}`,
+			wantErr: true,
+		},
+		{
+			content: `{"Version": `,
+			wantErr: true,
+		},
+	}
+
+	for i, c := range cases {
+		fn := filepath.Join(dir, "config.json")
+		os.Remove(fn)
+		if len(c.content) > 0 {
+			writeFile(t, fn, c.content)
+		}
+
+		got, err := Load(fn)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("%d: Load() error == %v, want error %v", i, err, c.wantErr)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+		want := Default()
+		want.resolve(dir)
+		c.want(&want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: Load() == %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "tripist", "config.json")
+	c := Default()
+	c.APIKeys.TripitAPIKey = "key"
+	c.Checklist = "/abs/list.csv"
+	c.Credentials.File = "/abs/user.json"
+	c.HomeTimezone = "Europe/Dublin"
+	c.StateFile = "/abs/state.json"
+	c.TodoistCache = ""
+
+	if err := Save(c, fn); err != nil {
+		t.Fatalf("Save() == %v", err)
+	}
+	fi, err := os.Stat(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := fi.Mode().Perm(); m != 0600 {
+		t.Errorf("Save() wrote mode %#o, want 0600", m)
+	}
+
+	got, err := Load(fn)
+	if err != nil {
+		t.Fatalf("Load() == %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("Load() == %+v, want %+v", got, c)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv(ChecklistEnv, "env.csv")
+	t.Setenv(TaskCutoffDaysEnv, "14")
+	t.Setenv(WorkersEnv, "2")
+	t.Setenv(TodoistClientIDEnv, "")
+
+	c := Default()
+	c.APIKeys.TodoistClientID = "from-file"
+	c.Project.Color = "blue"
+	if err := ApplyEnv(&c); err != nil {
+		t.Fatalf("ApplyEnv() == %v", err)
+	}
+
+	want := Default()
+	want.Checklist = "env.csv"
+	want.TaskCutoffDays = 14
+	want.Workers = 2
+	want.Project.Color = "blue"
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("ApplyEnv() == %+v, want %+v", c, want)
+	}
+
+	t.Setenv(TaskCutoffDaysEnv, "a week")
+	if err := ApplyEnv(&c); err == nil {
+		t.Errorf("ApplyEnv() with %s=%q succeeded, want error", TaskCutoffDaysEnv, "a week")
+	}
+}
+
+func TestFileEnvs(t *testing.T) {
+	t.Setenv(ChecklistEnv, "env.csv")
+	if got := FileEnvs(); len(got) != 0 {
+		t.Errorf("FileEnvs() == %v, want none", got)
+	}
+
+	t.Setenv(StateFileEnv, "state.json")
+	t.Setenv(TodoistCacheEnv, "")
+	if got, want := FileEnvs(), []string{StateFileEnv, TodoistCacheEnv}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FileEnvs() == %v, want %v", got, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	old := t.TempDir()
+	fn := filepath.Join(t.TempDir(), "tripist", "config.json")
+
+	if ok, err := Migrate(old, fn); ok || err != nil {
+		t.Errorf("Migrate() with nothing to migrate == %v, %v; want false, nil", ok, err)
+	}
+
+	writeFile(t, filepath.Join(old, "tripist.json"), `{"TripitAPIKey": "k", "TodoistClientID": "id"}`)
+	writeFile(t, filepath.Join(old, "user.json"), `{"TodoistToken": "t"}`)
+	writeFile(t, filepath.Join(old, "checklist.csv"), "Pack,-1d\n")
+
+	ok, err := Migrate(old, fn)
+	if !ok || err != nil {
+		t.Fatalf("Migrate() == %v, %v; want true, nil", ok, err)
+	}
+
+	got, err := Load(fn)
+	if err != nil {
+		t.Fatalf("Load() == %v", err)
+	}
+	want := Default()
+	want.resolve(filepath.Dir(fn))
+	want.APIKeys = APIKeys{TripitAPIKey: "k", TodoistClientID: "id"}
+	want.Checklist = filepath.Join(old, "checklist.csv")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() after Migrate() == %+v, want %+v", got, want)
+	}
+
+	b, err := os.ReadFile(want.Credentials.File)
+	if err != nil || string(b) != `{"TodoistToken": "t"}` {
+		t.Errorf("migrated tokens == %q, %v", b, err)
+	}
+
+	// Migration only happens once.
+	if ok, err := Migrate(old, fn); ok || err != nil {
+		t.Errorf("second Migrate() == %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "config.json")
+	writeFile(t, fn, `{
+		"Checklist": "shared.csv",
+		"TaskCutoffDays": 5,
+		"Trips": {"Overrides": {"T0": {"Checklist": "ski.csv"}}},
+		"Profiles": {
+			"alice": {"Project": {"Parent": "Travel"}, "Trips": {"Overrides": {"T1": {"Skip": true}}}},
+			"bob": {"TaskCutoffDays": 10, "StateFile": "/abs/bob.json"}
+		}
+	}`)
+
+	c, err := Load(fn)
+	if err != nil {
+		t.Fatalf("Load() == %v", err)
+	}
+	if got, want := c.ProfileNames(), []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ProfileNames() == %v, want %v", got, want)
+	}
+
+	cases := []struct {
+		name string
+		want func(*Settings)
+	}{
+		{
+			name: "alice",
+			want: func(s *Settings) {
+				s.Project.Parent = "Travel"
+				s.Trips.Overrides["T1"] = rules.Override{Skip: true}
+				s.Credentials.File = filepath.Join(dir, "alice", "user.json")
+				s.StateFile = filepath.Join(dir, "alice", "state.json")
+				s.TodoistCache = filepath.Join(dir, "alice", "todoist-cache.json")
+			},
+		},
+		{
+			name: "bob",
+			want: func(s *Settings) {
+				s.TaskCutoffDays = 10
+				s.Credentials.File = filepath.Join(dir, "bob", "user.json")
+				s.StateFile = "/abs/bob.json"
+				s.TodoistCache = filepath.Join(dir, "bob", "todoist-cache.json")
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := c.Profile(tc.name)
+		if err != nil {
+			t.Errorf("Profile(%q) == %v", tc.name, err)
+			continue
+		}
+		want := Default().Settings
+		want.Checklist = filepath.Join(dir, "shared.csv")
+		want.TaskCutoffDays = 5
+		want.Credentials.Store = "plain"
+		want.Trips.Overrides = map[string]rules.Override{"T0": {Checklist: filepath.Join(dir, "ski.csv")}}
+		tc.want(&want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Profile(%q) == %+v, want %+v", tc.name, got, want)
+		}
+	}
+
+	// Profiles' rules are their own.
+	if got := len(c.Settings.Trips.Overrides); got != 1 {
+		t.Errorf("top-level Trips.Overrides == %v, want only T0", c.Settings.Trips.Overrides)
+	}
+
+	if _, err := c.Profile(DefaultProfile); err == nil {
+		t.Errorf("Profile(%q) with profiles succeeded, want error", DefaultProfile)
+	}
+
+	writeFile(t, fn, `{"Profiles": {"../eve": {}}}`)
+	if _, err := Load(fn); err == nil {
+		t.Errorf("Load() with profile %q succeeded, want error", "../eve")
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Environment variables which override the configuration file.
+const (
+	TripitAPIKeyEnv        = "TRIPIST_TRIPIT_API_KEY"
+	TripitAPISecretEnv     = "TRIPIST_TRIPIT_API_SECRET"
+	TodoistClientIDEnv     = "TRIPIST_TODOIST_CLIENT_ID"
+	TodoistClientSecretEnv = "TRIPIST_TODOIST_CLIENT_SECRET"
+	CredentialsEnv         = "TRIPIST_CREDENTIALS"
+	CredentialsFileEnv     = "TRIPIST_CREDENTIALS_FILE"
+	CredentialsKeyFileEnv  = "TRIPIST_CREDENTIALS_KEY_FILE"
+	ChecklistEnv           = "TRIPIST_CHECKLIST"
+	ChecklistRulesEnv      = "TRIPIST_CHECKLIST_RULES"
+	TaskCutoffDaysEnv      = "TRIPIST_TASK_CUTOFF_DAYS"
+	HomeTimezoneEnv        = "TRIPIST_HOME_TIMEZONE"
+	ProjectParentEnv       = "TRIPIST_PROJECT_PARENT"
+	ProjectColorEnv        = "TRIPIST_PROJECT_COLOR"
+	ProjectNameTemplateEnv = "TRIPIST_PROJECT_NAME_TEMPLATE"
+	StateFileEnv           = "TRIPIST_STATE_FILE"
+	TodoistCacheEnv        = "TRIPIST_TODOIST_CACHE"
+	WorkersEnv             = "TRIPIST_WORKERS"
+)
+
+// fileEnvs set a profile's files. Like the equivalent flags, they can only
+// be used with a single profile, lest profiles share them.
+var fileEnvs = []string{CredentialsFileEnv, StateFileEnv, TodoistCacheEnv}
+
+// FileEnvs returns the environment variables setting a profile's files
+// (TRIPIST_CREDENTIALS_FILE, TRIPIST_STATE_FILE and TRIPIST_TODOIST_CACHE)
+// which are set. They should only be used with a single profile.
+func FileEnvs() []string {
+	var ret []string
+	for _, env := range fileEnvs {
+		if _, ok := os.LookupEnv(env); ok {
+			ret = append(ret, env)
+		}
+	}
+	return ret
+}
+
+// ApplyEnv overrides settings in c, and all its profiles, with those set in
+// the environment.
+func ApplyEnv(c *Config) error {
+	lookup(map[string]*string{
+		TripitAPIKeyEnv:        &c.APIKeys.TripitAPIKey,
+		TripitAPISecretEnv:     &c.APIKeys.TripitAPISecret,
+		TodoistClientIDEnv:     &c.APIKeys.TodoistClientID,
+		TodoistClientSecretEnv: &c.APIKeys.TodoistClientSecret,
+	})
+
+	if err := applyEnv(&c.Settings); err != nil {
+		return err
+	}
+	for n, p := range c.Profiles {
+		if err := applyEnv(&p); err != nil {
+			return err
+		}
+		c.Profiles[n] = p
+	}
+	return nil
+}
+
+func lookup(vars map[string]*string) {
+	for env, p := range vars {
+		if v, ok := os.LookupEnv(env); ok {
+			*p = v
+		}
+	}
+}
+
+func applyEnv(c *Settings) error {
+	lookup(map[string]*string{
+		CredentialsEnv:         &c.Credentials.Store,
+		CredentialsFileEnv:     &c.Credentials.File,
+		CredentialsKeyFileEnv:  &c.Credentials.KeyFile,
+		ChecklistEnv:           &c.Checklist,
+		ChecklistRulesEnv:      &c.ChecklistRules,
+		HomeTimezoneEnv:        &c.HomeTimezone,
+		ProjectParentEnv:       &c.Project.Parent,
+		ProjectColorEnv:        &c.Project.Color,
+		ProjectNameTemplateEnv: &c.Project.NameTemplate,
+		StateFileEnv:           &c.StateFile,
+		TodoistCacheEnv:        &c.TodoistCache,
+	})
+
+	return lookupInt(map[string]*int{
+		TaskCutoffDaysEnv: &c.TaskCutoffDays,
+		WorkersEnv:        &c.Workers,
+	})
+}
+
+func lookupInt(vars map[string]*int) error {
+	for env, p := range vars {
+		if v, ok := os.LookupEnv(env); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("$%s: %v", env, err)
+			}
+			*p = n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Files used before the configuration file existed, in the working
+// directory.
+const (
+	legacyAPIKeys   = "tripist.json"
+	legacyUserKeys  = "user.json"
+	legacyChecklist = "checklist.csv"
+	legacyState     = "state.json"
+	legacyCache     = "todoist-cache.json"
+)
+
+// Migrate creates the configuration file filename from the files older
+// versions kept in dir: API keys from tripist.json, tokens from user.json
+// (copied alongside filename), and the checklist, state and Todoist cache,
+// which are referred to where they are. It does nothing if filename exists
+// or there is nothing to migrate, and reports whether it wrote filename. The
+// old files are left in place.
+func Migrate(dir, filename string) (bool, error) {
+	if _, err := os.Stat(filename); !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	apiKeys, err := readLegacy(filepath.Join(dir, legacyAPIKeys))
+	if err != nil {
+		return false, err
+	}
+	userKeys, err := readLegacy(filepath.Join(dir, legacyUserKeys))
+	if err != nil {
+		return false, err
+	}
+	if apiKeys == nil && userKeys == nil {
+		return false, nil
+	}
+
+	c := Default()
+	if apiKeys != nil {
+		if err := json.Unmarshal(apiKeys, &c.APIKeys); err != nil {
+			return false, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return false, err
+	}
+	if userKeys != nil {
+		if err := writePrivate(filepath.Join(filepath.Dir(filename), c.Credentials.File), userKeys); err != nil {
+			return false, err
+		}
+		// user.json may have been written by the encrypted credential
+		// store.
+		var enc struct{ Ciphertext []byte }
+		if json.Unmarshal(userKeys, &enc) == nil && enc.Ciphertext != nil {
+			c.Credentials.Store = "encrypted"
+		}
+	}
+
+	for fn, p := range map[string]*string{
+		legacyChecklist: &c.Checklist,
+		legacyState:     &c.StateFile,
+		legacyCache:     &c.TodoistCache,
+	} {
+		if abs, err := filepath.Abs(filepath.Join(dir, fn)); err == nil {
+			if _, err := os.Stat(abs); err == nil {
+				*p = abs
+			}
+		}
+	}
+
+	if err := Save(c, filename); err != nil {
+		return false, err
+	}
+	slog.Info("Migrated legacy configuration; the old files can be removed", "from", filepath.Join(dir, legacyAPIKeys), "to", filename)
+	return true, nil
+}
+
+// readLegacy returns the contents of filename, or nil if it does not exist.
+func readLegacy(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Find loads the configuration from filename, or DefaultPath if filename is
+// empty, then applies overrides from the environment. The default file is
+// migrated from the working directory's old files if it does not exist yet.
+func Find(filename string) (Config, string, error) {
+	if len(filename) == 0 {
+		var err error
+		filename, err = DefaultPath()
+		if err != nil {
+			return Config{}, "", err
+		}
+		if _, err := Migrate(".", filename); err != nil {
+			return Config{}, filename, err
+		}
+	}
+
+	c, err := Load(filename)
+	if err != nil {
+		return c, filename, err
+	}
+	return c, filename, ApplyEnv(&c)
+}
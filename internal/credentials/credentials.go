@@ -0,0 +1,121 @@
+// Package credentials stores the user's TripIt and Todoist tokens.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/seanrees/tripist/internal/config"
+)
+
+// A Store loads and saves the user's tokens.
+type Store interface {
+	// Load returns the stored tokens. If nothing has been stored yet, the
+	// tokens are empty and there is no error.
+	Load() (config.UserKeys, error)
+
+	// Save replaces the stored tokens.
+	Save(config.UserKeys) error
+}
+
+// Kinds of Store, for New.
+const (
+	Plain     = "plain"
+	Encrypted = "encrypted"
+)
+
+// Options for New.
+type Options struct {
+	// Kind of store: Plain or Encrypted.
+	Kind string
+
+	// Filename of the store.
+	Filename string
+
+	// KeyFile, if set, holds the secret for an Encrypted store. Otherwise
+	// the secret is the passphrase in $TRIPIST_PASSPHRASE.
+	KeyFile string
+}
+
+// PassphraseEnv is the environment variable holding the passphrase for an
+// Encrypted store.
+const PassphraseEnv = "TRIPIST_PASSPHRASE"
+
+// New returns the Store described by o. Tokens in the environment (see
+// WithEnv) take precedence over stored ones.
+func New(o Options) (Store, error) {
+	switch o.Kind {
+	case Plain:
+		return WithEnv(&PlainFile{Filename: o.Filename}), nil
+
+	case Encrypted:
+		var secret []byte
+		if len(o.KeyFile) > 0 {
+			b, err := os.ReadFile(o.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read key file: %v", err)
+			}
+			secret = b
+		} else if p := os.Getenv(PassphraseEnv); len(p) > 0 {
+			secret = []byte(p)
+		} else {
+			return nil, fmt.Errorf("encrypted credentials need a key file or $%s", PassphraseEnv)
+		}
+		return WithEnv(&EncryptedFile{Filename: o.Filename, Secret: secret}), nil
+	}
+	return nil, fmt.Errorf("unknown credential store %q (want %s or %s)", o.Kind, Plain, Encrypted)
+}
+
+// readFile returns the contents of filename, or nil if it does not exist.
+func readFile(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// checkMode warns if filename is readable by anyone but its owner.
+func checkMode(filename string) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return
+	}
+	if m := fi.Mode().Perm(); m&0077 != 0 {
+		slog.Warn("Credentials are accessible by other users; they will be made private when next saved", "file", filename, "mode", fmt.Sprintf("%#o", m))
+	}
+}
+
+// writeFile atomically replaces filename with b, readable only by the user.
+// Its directory is created if needed.
+func writeFile(filename string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
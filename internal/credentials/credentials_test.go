@@ -0,0 +1,180 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanrees/tripist/internal/config"
+)
+
+var testKeys = config.UserKeys{
+	TripitToken:  "tripit-token",
+	TripitSecret: "tripit-secret",
+	TodoistToken: "todoist-token",
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []struct {
+		name  string
+		store Store
+	}{
+		{name: "plain", store: &PlainFile{Filename: filepath.Join(dir, "plain.json")}},
+		{name: "encrypted", store: &EncryptedFile{Filename: filepath.Join(dir, "enc.json"), Secret: []byte("hunter2")}},
+	}
+
+	for _, c := range cases {
+		got, err := c.store.Load()
+		if err != nil {
+			t.Errorf("%s: Load() of missing file: %v", c.name, err)
+		}
+		if got != (config.UserKeys{}) {
+			t.Errorf("%s: Load() of missing file == %+v, want empty", c.name, got)
+		}
+
+		if err := c.store.Save(testKeys); err != nil {
+			t.Fatalf("%s: Save() == %v", c.name, err)
+		}
+		got, err = c.store.Load()
+		if err != nil {
+			t.Errorf("%s: Load() == %v", c.name, err)
+		}
+		if got != testKeys {
+			t.Errorf("%s: Load() == %+v, want %+v", c.name, got, testKeys)
+		}
+	}
+}
+
+func TestSaveMode(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "user.json")
+	if err := os.WriteFile(fn, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&PlainFile{Filename: fn}).Save(testKeys); err != nil {
+		t.Fatalf("Save() == %v", err)
+	}
+	fi, err := os.Stat(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := fi.Mode().Perm(); m != 0600 {
+		t.Errorf("Save() left mode %#o, want 0600", m)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "enc.json")
+	if err := (&EncryptedFile{Filename: fn, Secret: []byte("right")}).Save(testKeys); err != nil {
+		t.Fatalf("Save() == %v", err)
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{testKeys.TripitToken, testKeys.TripitSecret, testKeys.TodoistToken} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("encrypted file contains %q", s)
+		}
+	}
+
+	if _, err := (&EncryptedFile{Filename: fn, Secret: []byte("wrong")}).Load(); err == nil {
+		t.Errorf("Load() with wrong secret succeeded, want error")
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "user.json")
+	p := &PlainFile{Filename: fn}
+	if err := p.Save(testKeys); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(TodoistTokenEnv, "from-env")
+
+	s := WithEnv(p)
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() == %v", err)
+	}
+	want := testKeys
+	want.TodoistToken = "from-env"
+	if got != want {
+		t.Errorf("Load() == %+v, want %+v", got, want)
+	}
+
+	// Tokens from the environment are not saved.
+	if err := s.Save(got); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := p.Load(); got != testKeys {
+		t.Errorf("PlainFile.Load() == %+v, want %+v", got, testKeys)
+	}
+}
+
+func TestWithEnvSave(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "user.json")
+	p := &PlainFile{Filename: fn}
+	if err := p.Save(testKeys); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(TripitTokenEnv, "env-tripit-token")
+	t.Setenv(TripitSecretEnv, "env-tripit-secret")
+	t.Setenv(TodoistTokenEnv, "env-todoist-token")
+
+	// As when authorizing Todoist: its token changes, TripIt's do not.
+	s := WithEnv(p)
+	uk, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() == %v", err)
+	}
+	uk.TodoistToken = "new-todoist-token"
+	if err := s.Save(uk); err != nil {
+		t.Fatalf("Save() == %v", err)
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"env-tripit-token", "env-tripit-secret", "env-todoist-token"} {
+		if strings.Contains(string(b), env) {
+			t.Errorf("Save() wrote environment token %q to %s: %s", env, fn, b)
+		}
+	}
+
+	want := testKeys
+	want.TodoistToken = "new-todoist-token"
+	if got, _ := p.Load(); got != want {
+		t.Errorf("PlainFile.Load() == %+v, want %+v", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+
+	cases := []struct {
+		opts    Options
+		wantErr bool
+	}{
+		{opts: Options{Kind: Plain, Filename: "user.json"}},
+		{opts: Options{Kind: Encrypted, Filename: "user.json"}, wantErr: true},
+		{opts: Options{Kind: Encrypted, Filename: "user.json", KeyFile: "/does/not/exist"}, wantErr: true},
+		{opts: Options{Kind: "keyring"}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		_, err := New(c.opts)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("New(%+v) == %v, want error %v", c.opts, err, c.wantErr)
+		}
+	}
+}
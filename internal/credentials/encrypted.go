@@ -0,0 +1,114 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/seanrees/tripist/internal/config"
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters, as recommended for interactive logins in 2017.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	keyLen       = 32
+	saltLen      = 16
+	fileVersion  = 1
+	fileKDFLabel = "scrypt"
+)
+
+// EncryptedFile stores tokens encrypted with AES-256-GCM, using a key derived
+// from Secret (a passphrase or the contents of a key file) with scrypt.
+type EncryptedFile struct {
+	Filename string
+	Secret   []byte
+}
+
+// encryptedFile is the on-disk format of an EncryptedFile.
+type encryptedFile struct {
+	Version    int
+	KDF        string
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+func (e *EncryptedFile) key(salt []byte) ([]byte, error) {
+	return scrypt.Key(e.Secret, salt, scryptN, scryptR, scryptP, keyLen)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+func (e *EncryptedFile) Load() (config.UserKeys, error) {
+	uk := config.UserKeys{}
+	b, err := readFile(e.Filename)
+	if err != nil || b == nil {
+		return uk, err
+	}
+
+	var ef encryptedFile
+	if err := json.Unmarshal(b, &ef); err != nil {
+		return uk, err
+	}
+	if ef.Version != fileVersion || ef.KDF != fileKDFLabel {
+		return uk, fmt.Errorf("unsupported credentials file version %d (%s)", ef.Version, ef.KDF)
+	}
+
+	key, err := e.key(ef.Salt)
+	if err != nil {
+		return uk, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return uk, err
+	}
+	plain, err := gcm.Open(nil, ef.Nonce, ef.Ciphertext, nil)
+	if err != nil {
+		return uk, fmt.Errorf("unable to decrypt %s (wrong passphrase or key file?)", e.Filename)
+	}
+
+	err = json.Unmarshal(plain, &uk)
+	return uk, err
+}
+
+func (e *EncryptedFile) Save(uk config.UserKeys) error {
+	plain, err := json.Marshal(uk)
+	if err != nil {
+		return err
+	}
+
+	ef := encryptedFile{Version: fileVersion, KDF: fileKDFLabel, Salt: make([]byte, saltLen)}
+	if _, err := rand.Read(ef.Salt); err != nil {
+		return err
+	}
+	key, err := e.key(ef.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	ef.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(ef.Nonce); err != nil {
+		return err
+	}
+	ef.Ciphertext = gcm.Seal(nil, ef.Nonce, plain, nil)
+
+	b, err := json.MarshalIndent(ef, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(e.Filename, b)
+}
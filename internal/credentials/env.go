@@ -0,0 +1,69 @@
+package credentials
+
+import (
+	"os"
+
+	"github.com/seanrees/tripist/internal/config"
+)
+
+// Environment variables which override stored tokens.
+const (
+	TripitTokenEnv  = "TRIPIST_TRIPIT_TOKEN"
+	TripitSecretEnv = "TRIPIST_TRIPIT_SECRET"
+	TodoistTokenEnv = "TRIPIST_TODOIST_TOKEN"
+)
+
+// envStore overlays tokens from the environment on another Store.
+type envStore struct {
+	Store
+}
+
+// WithEnv returns a Store whose Load prefers tokens set in the environment
+// (TRIPIST_TRIPIT_TOKEN, TRIPIST_TRIPIT_SECRET and TRIPIST_TODOIST_TOKEN) to
+// those stored in s. Save keeps the stored token in place of any that came
+// from the environment, so environment tokens are never written out.
+func WithEnv(s Store) Store {
+	return envStore{s}
+}
+
+// envTokens returns the tokens of uk that may be set in the environment, by
+// environment variable.
+func envTokens(uk *config.UserKeys) map[string]*string {
+	return map[string]*string{
+		TripitTokenEnv:  &uk.TripitToken,
+		TripitSecretEnv: &uk.TripitSecret,
+		TodoistTokenEnv: &uk.TodoistToken,
+	}
+}
+
+func (e envStore) Load() (config.UserKeys, error) {
+	uk, err := e.Store.Load()
+	if err != nil {
+		return uk, err
+	}
+
+	for env, v := range envTokens(&uk) {
+		if s, ok := os.LookupEnv(env); ok {
+			*v = s
+		}
+	}
+	return uk, nil
+}
+
+// Save saves uk, except for tokens that are still those set in the
+// environment: the stored tokens are kept for them instead. A token that has
+// changed, e.g; by authorizing again, is saved.
+func (e envStore) Save(uk config.UserKeys) error {
+	stored, err := e.Store.Load()
+	if err != nil {
+		return err
+	}
+
+	saved := envTokens(&stored)
+	for env, v := range envTokens(&uk) {
+		if s, ok := os.LookupEnv(env); ok && *v == s {
+			*v = *saved[env]
+		}
+	}
+	return e.Store.Save(uk)
+}
@@ -0,0 +1,32 @@
+package credentials
+
+import (
+	"encoding/json"
+
+	"github.com/seanrees/tripist/internal/config"
+)
+
+// PlainFile stores tokens as JSON in a file only the user can read.
+type PlainFile struct {
+	Filename string
+}
+
+func (p *PlainFile) Load() (config.UserKeys, error) {
+	uk := config.UserKeys{}
+	b, err := readFile(p.Filename)
+	if err != nil || b == nil {
+		return uk, err
+	}
+	checkMode(p.Filename)
+
+	err = json.Unmarshal(b, &uk)
+	return uk, err
+}
+
+func (p *PlainFile) Save(uk config.UserKeys) error {
+	b, err := json.MarshalIndent(uk, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(p.Filename, b)
+}
@@ -0,0 +1,36 @@
+// Package lock provides a lock file, so that only one process syncs at a
+// time.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrLocked is returned by Acquire if another process holds the lock.
+var ErrLocked = errors.New("locked by another process")
+
+// A Lock is held until Released.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire takes the lock in filename, creating the file if needed. It does
+// not wait: if the lock is held, it returns ErrLocked.
+func Acquire(filename string) (*Lock, error) {
+	f, err := acquire(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// For the curious; the lock itself does not depend on it.
+	f.Truncate(0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	return &Lock{f: f}, nil
+}
+
+// Release gives up the lock.
+func (l *Lock) Release() error {
+	return release(l.f)
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package lock
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// Without flock(2), the lock is the existence of the file. A process that
+// dies holding it leaves the file behind, which must be removed by hand.
+func acquire(filename string) (*os.File, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, ErrLocked
+	}
+	return f, err
+}
+
+func release(f *os.File) error {
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,30 @@
+package lock
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquire(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "tripist.lock")
+
+	l, err := Acquire(fn)
+	if err != nil {
+		t.Fatalf("Acquire() == %v", err)
+	}
+
+	if _, err := Acquire(fn); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Acquire() == %v, want %v", err, ErrLocked)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Errorf("Release() == %v", err)
+	}
+
+	l, err = Acquire(fn)
+	if err != nil {
+		t.Fatalf("Acquire() after Release() == %v", err)
+	}
+	l.Release()
+}
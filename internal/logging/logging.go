@@ -0,0 +1,87 @@
+// Package logging sets up structured logging with log/slog.
+//
+// Records logged with a context (e.g; slog.InfoContext) carry the
+// attributes added to it with With, so that messages about a trip can be
+// told apart when several are processed. Values registered with Redact
+// never appear in the output.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Formats for Setup.
+const (
+	Text = "text"
+	JSON = "json"
+)
+
+// Setup makes a logger writing to w in format (Text or JSON) the default,
+// for both log/slog and log. level is a name understood by slog.Level, e.g;
+// "debug" or "warn".
+func Setup(w io.Writer, format, level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+
+	// Source locations are noise except when debugging.
+	opts := &slog.HandlerOptions{Level: l, AddSource: l <= slog.LevelDebug}
+
+	var h slog.Handler
+	switch format {
+	case Text:
+		h = slog.NewTextHandler(w, opts)
+	case JSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (want %s or %s)", format, Text, JSON)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{redactHandler{h}}))
+	return nil
+}
+
+type ctxKey struct{}
+
+// With returns a context whose log records carry args (as for slog.With)
+// in addition to those already in ctx.
+func With(ctx context.Context, args ...any) context.Context {
+	var attrs []slog.Attr
+	if a, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		attrs = append(attrs, a...)
+	}
+	r := slog.Record{}
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// contextHandler adds the attributes from With to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if a, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+			r = r.Clone()
+			r.AddAttrs(a...)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
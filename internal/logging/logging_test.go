@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// capture sets up logging to a buffer for the duration of the test.
+func capture(t *testing.T, format, level string) *bytes.Buffer {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var b bytes.Buffer
+	if err := Setup(&b, format, level); err != nil {
+		t.Fatalf("Setup(%q, %q) == %v", format, level, err)
+	}
+	return &b
+}
+
+func records(t *testing.T, b *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var ret []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if len(l) == 0 {
+			continue
+		}
+		r := map[string]any{}
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatalf("unmarshal %q: %v", l, err)
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func TestSetup(t *testing.T) {
+	cases := []struct {
+		format, level string
+		wantErr       bool
+	}{
+		{format: Text, level: "info"},
+		{format: JSON, level: "debug"},
+		{format: JSON, level: "WARN"},
+		{format: "xml", level: "info", wantErr: true},
+		{format: Text, level: "loud", wantErr: true},
+	}
+
+	for _, c := range cases {
+		prev := slog.Default()
+		err := Setup(&bytes.Buffer{}, c.format, c.level)
+		slog.SetDefault(prev)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("Setup(%q, %q) == %v, want error %v", c.format, c.level, err, c.wantErr)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	b := capture(t, JSON, "info")
+
+	ctx := With(context.Background(), "trip_id", "t1")
+	ctx = With(ctx, slog.String("project_id", "p1"))
+	slog.InfoContext(ctx, "Processing", "tasks", 3)
+	slog.DebugContext(ctx, "Not shown")
+	slog.Info("No context")
+
+	rs := records(t, b)
+	if len(rs) != 2 {
+		t.Fatalf("got %d records, want 2: %s", len(rs), b)
+	}
+	for k, want := range map[string]any{"msg": "Processing", "trip_id": "t1", "project_id": "p1", "tasks": 3.0} {
+		if got := rs[0][k]; got != want {
+			t.Errorf("record[%q] == %v, want %v", k, got, want)
+		}
+	}
+	if _, ok := rs[1]["trip_id"]; ok {
+		t.Errorf("record without context has trip_id: %v", rs[1])
+	}
+}
+
+func TestRedact(t *testing.T) {
+	b := capture(t, JSON, "info")
+
+	const token = "s3cr3t-t0k3n-value"
+	Redact(token, "short")
+
+	ctx := With(context.Background(), "header", "Bearer "+token)
+	slog.InfoContext(ctx, "Token is "+token,
+		"token", token,
+		"err", errors.New("bad token "+token),
+		slog.Group("g", "inner", token))
+	log.Printf("via log: %s", token)
+	slog.Info("Unrelated short text")
+
+	if strings.Contains(b.String(), token) {
+		t.Errorf("output contains the token: %s", b)
+	}
+	if !strings.Contains(b.String(), Redacted) {
+		t.Errorf("output does not contain %q: %s", Redacted, b)
+	}
+	if !strings.Contains(b.String(), "Unrelated short text") {
+		t.Errorf("short value was redacted: %s", b)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	b := capture(t, JSON, "debug")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := &http.Client{Transport: Transport("todoist", nil)}
+	resp, err := c.Get(srv.URL + "/sync?token=hidden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	rs := records(t, b)
+	if len(rs) != 2 {
+		t.Fatalf("got %d records, want 2: %s", len(rs), b)
+	}
+	if got, want := rs[1]["status"], 200.0; got != want {
+		t.Errorf("response status == %v, want %v", got, want)
+	}
+	if strings.Contains(b.String(), "hidden") {
+		t.Errorf("output contains the query string: %s", b)
+	}
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in log output.
+const Redacted = "[REDACTED]"
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// Redact registers values, such as tokens, that must never be logged. They
+// are replaced with Redacted wherever they appear in a message or attribute.
+func Redact(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, v := range values {
+		// Very short values would mangle unrelated text.
+		if len(v) >= 8 {
+			secrets = append(secrets, v)
+		}
+	}
+}
+
+func redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, v := range secrets {
+		s = strings.ReplaceAll(s, v, Redacted)
+	}
+	return s
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact(v.String()))
+
+	case slog.KindAny:
+		// Errors and the like: whatever they print as.
+		return slog.String(a.Key, redact(v.String()))
+
+	case slog.KindGroup:
+		var as []any
+		for _, ga := range v.Group() {
+			as = append(as, redactAttr(ga))
+		}
+		return slog.Group(a.Key, as...)
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// redactHandler removes registered secrets from records.
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, nr)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var ra []slog.Attr
+	for _, a := range attrs {
+		ra = append(ra, redactAttr(a))
+	}
+	return redactHandler{h.Handler.WithAttrs(ra)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Transport returns next (or http.DefaultTransport, if nil) logging a
+// summary of each request and response at debug level. Only the method,
+// host, path and status are logged: never headers, query strings or bodies,
+// which may carry tokens.
+func Transport(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper{service: service, next: next}
+}
+
+type roundTripper struct {
+	service string
+	next    http.RoundTripper
+}
+
+func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return r.next.RoundTrip(req)
+	}
+
+	attrs := []any{
+		slog.String("service", r.service),
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Host+req.URL.Path),
+	}
+	if req.ContentLength > 0 {
+		attrs = append(attrs, slog.Int64("request_bytes", req.ContentLength))
+	}
+	slog.DebugContext(ctx, "HTTP request", attrs...)
+
+	start := time.Now()
+	resp, err := r.next.RoundTrip(req)
+	attrs = append(attrs, slog.Duration("elapsed", time.Since(start)))
+	if err != nil {
+		slog.DebugContext(ctx, "HTTP request failed", append(attrs, slog.Any("error", err))...)
+		return resp, err
+	}
+
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	if resp.ContentLength >= 0 {
+		attrs = append(attrs, slog.Int64("response_bytes", resp.ContentLength))
+	}
+	slog.DebugContext(ctx, "HTTP response", attrs...)
+	return resp, nil
+}
@@ -0,0 +1,123 @@
+// Package loopback receives OAuth redirects on a temporary local HTTP server,
+// so authorization codes don't need to be copied and pasted.
+package loopback
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+)
+
+// CallbackPath is where the server expects the redirect.
+const CallbackPath = "/callback"
+
+// StateParam is the query parameter carrying the state token.
+const StateParam = "state"
+
+// Server is a one-shot HTTP server on 127.0.0.1 which waits for a redirect.
+type Server struct {
+	ln     net.Listener
+	srv    *http.Server
+	state  string
+	result chan url.Values
+}
+
+// Listen starts a server on 127.0.0.1:port (0 picks a free port). The server
+// only accepts a redirect carrying its random state.
+func Listen(port int) (*Server, error) {
+	state, err := RandomState()
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{ln: ln, state: state, result: make(chan url.Values, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(CallbackPath, s.callback)
+	s.srv = &http.Server{Handler: mux}
+
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// URL is the redirect URL to give the authorization server.
+func (s *Server) URL() string {
+	return fmt.Sprintf("http://%s%s", s.ln.Addr(), CallbackPath)
+}
+
+// State is the random state token the redirect must carry.
+func (s *Server) State() string {
+	return s.state
+}
+
+func (s *Server) callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(q.Get(StateParam)), []byte(s.state)) != 1 {
+		http.Error(w, "Invalid state; please restart authorization.", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case s.result <- q:
+		fmt.Fprintln(w, "Authorization received; you can close this window and return to tripist.")
+	default:
+		http.Error(w, "Authorization already received.", http.StatusConflict)
+	}
+}
+
+// Wait returns the query parameters of the first redirect with a valid state.
+func (s *Server) Wait(ctx context.Context) (url.Values, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case q := <-s.result:
+		if e := q.Get("error"); len(e) > 0 {
+			return q, fmt.Errorf("authorization failed: %s", e)
+		}
+		return q, nil
+	}
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	err := s.srv.Close()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// RandomState returns an unguessable token for an OAuth state parameter.
+func RandomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// OpenBrowser tries to open u in the user's browser.
+func OpenBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,65 @@
+package loopback
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	s, err := Listen(0)
+	if err != nil {
+		t.Fatalf("Listen(0) == error (%v), want no error", err)
+	}
+	defer s.Close()
+
+	get := func(q url.Values) int {
+		resp, err := http.Get(s.URL() + "?" + q.Encode())
+		if err != nil {
+			t.Fatalf("GET callback == error (%v), want no error", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Wrong state is rejected, and doesn't complete the wait.
+	if got := get(url.Values{"code": {"evil"}, StateParam: {"guess"}}); got != http.StatusBadRequest {
+		t.Errorf("callback(bad state) == %d, want %d", got, http.StatusBadRequest)
+	}
+
+	if got := get(url.Values{"code": {"good"}, StateParam: {s.State()}}); got != http.StatusOK {
+		t.Errorf("callback(good state) == %d, want %d", got, http.StatusOK)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	q, err := s.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Wait() == error (%v), want no error", err)
+	}
+	if got := q.Get("code"); got != "good" {
+		t.Errorf("Wait() code == %q, want good", got)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	s, err := Listen(0)
+	if err != nil {
+		t.Fatalf("Listen(0) == error (%v), want no error", err)
+	}
+	defer s.Close()
+
+	resp, err := http.Get(s.URL() + "?" + url.Values{"error": {"access_denied"}, StateParam: {s.State()}}.Encode())
+	if err != nil {
+		t.Fatalf("GET callback == error (%v), want no error", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.Wait(ctx); err == nil {
+		t.Errorf("Wait() == no error, want access_denied")
+	}
+}
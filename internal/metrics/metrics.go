@@ -0,0 +1,172 @@
+// Package metrics exports tripist's health to Prometheus.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Actions on tasks, for Tasks.
+const (
+	TasksCreated = "created"
+	TasksUpdated = "updated"
+)
+
+// Metrics records what tripist does. A nil *Metrics records nothing, so
+// callers need not check whether metrics are enabled.
+type Metrics struct {
+	reg *prometheus.Registry
+
+	syncRuns     prometheus.Counter
+	syncFailures prometheus.Counter
+	lastSuccess  prometheus.Gauge
+	consecutive  prometheus.Gauge
+	trips        *prometheus.CounterVec
+	tasks        *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	errors       *prometheus.CounterVec
+
+	mu sync.Mutex
+
+	// failures is the number of consecutive failed syncs.
+	failures int
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		reg: prometheus.NewRegistry(),
+		syncRuns: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tripist_sync_runs_total",
+			Help: "Syncs started.",
+		}),
+		syncFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tripist_sync_failures_total",
+			Help: "Syncs in which a profile or trip failed.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tripist_last_success_timestamp_seconds",
+			Help: "When the last fully successful sync finished.",
+		}),
+		consecutive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tripist_consecutive_failures",
+			Help: "Failed syncs since the last successful one.",
+		}),
+		trips: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tripist_trips_processed_total",
+			Help: "Trips processed, by profile.",
+		}, []string{"profile"}),
+		tasks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tripist_tasks_total",
+			Help: "Tasks created and updated in Todoist, by profile.",
+		}, []string{"profile", "action"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tripist_http_request_duration_seconds",
+			Help:    "Latency of requests to TripIt and Todoist, by HTTP status code.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+		}, []string{"service", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tripist_http_request_errors_total",
+			Help: "Requests to TripIt and Todoist that failed, by HTTP status code (or \"error\" if there was none).",
+		}, []string{"service", "code"}),
+	}
+
+	m.reg.MustRegister(m.syncRuns, m.syncFailures, m.lastSuccess, m.consecutive,
+		m.trips, m.tasks, m.latency, m.errors,
+		prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return m
+}
+
+// Sync records a sync that finished at t.
+func (m *Metrics) Sync(ok bool, t time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.syncRuns.Inc()
+	if ok {
+		m.failures = 0
+		m.lastSuccess.Set(float64(t.Unix()))
+	} else {
+		m.failures++
+		m.syncFailures.Inc()
+	}
+	m.consecutive.Set(float64(m.failures))
+}
+
+// Trips records n trips processed for profile.
+func (m *Metrics) Trips(profile string, n int) {
+	if m == nil {
+		return
+	}
+	m.trips.WithLabelValues(profile).Add(float64(n))
+}
+
+// Tasks records n tasks created or updated (see TasksCreated) for profile.
+func (m *Metrics) Tasks(profile, action string, n int) {
+	if m == nil {
+		return
+	}
+	m.tasks.WithLabelValues(profile, action).Add(float64(n))
+}
+
+// Transport returns next (or http.DefaultTransport, if nil) instrumented
+// to record the latency and errors of requests to service.
+func (m *Metrics) Transport(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if m == nil {
+		return next
+	}
+	return roundTripper{m: m, service: service, next: next}
+}
+
+type roundTripper struct {
+	m       *Metrics
+	service string
+	next    http.RoundTripper
+}
+
+func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		r.m.errors.WithLabelValues(r.service, "error").Inc()
+		return resp, err
+	}
+
+	code := strconv.Itoa(resp.StatusCode)
+	r.m.latency.WithLabelValues(r.service, code).Observe(time.Since(start).Seconds())
+	if resp.StatusCode >= 400 {
+		r.m.errors.WithLabelValues(r.service, code).Inc()
+	}
+	return resp, nil
+}
+
+// Handler serves metrics on /metrics, and health on /healthz: healthy until
+// unhealthyAfter syncs in a row have failed.
+func (m *Metrics) Handler(unhealthyAfter int) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		failures := m.failures
+		m.mu.Unlock()
+
+		if failures >= unhealthyAfter {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "unhealthy: %d consecutive failed syncs\n", failures)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
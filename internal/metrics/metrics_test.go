@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	b, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, string(b)
+}
+
+func TestHealthz(t *testing.T) {
+	m := New()
+	h := m.Handler(2)
+
+	cases := []struct {
+		ok   bool
+		want int
+	}{
+		{ok: false, want: http.StatusOK},
+		{ok: false, want: http.StatusServiceUnavailable},
+		{ok: false, want: http.StatusServiceUnavailable},
+		{ok: true, want: http.StatusOK},
+		{ok: false, want: http.StatusOK},
+	}
+
+	if code, _ := get(t, h, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz before any sync == %d, want %d", code, http.StatusOK)
+	}
+	for i, c := range cases {
+		m.Sync(c.ok, time.Unix(1700000000, 0))
+		if code, _ := get(t, h, "/healthz"); code != c.want {
+			t.Errorf("%d: /healthz after Sync(%v) == %d, want %d", i, c.ok, code, c.want)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	m := New()
+	c := &http.Client{Transport: m.Transport("todoist", nil)}
+	for _, p := range []string{"/ok", "/fail"} {
+		resp, err := c.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	m.Sync(true, time.Unix(1700000000, 0))
+	m.Trips("alice", 3)
+	m.Tasks("alice", TasksCreated, 5)
+
+	_, body := get(t, m.Handler(1), "/metrics")
+	for _, want := range []string{
+		`tripist_sync_runs_total 1`,
+		`tripist_last_success_timestamp_seconds 1.7e+09`,
+		`tripist_trips_processed_total{profile="alice"} 3`,
+		`tripist_tasks_total{action="created",profile="alice"} 5`,
+		`tripist_http_request_duration_seconds_count{code="200",service="todoist"} 1`,
+		`tripist_http_request_errors_total{code="429",service="todoist"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics does not contain %q", want)
+		}
+	}
+}
+
+func TestNil(t *testing.T) {
+	var m *Metrics
+	m.Sync(false, time.Now())
+	m.Trips("alice", 1)
+	m.Tasks("alice", TasksUpdated, 1)
+	if got := m.Transport("tripit", nil); got != http.DefaultTransport {
+		t.Errorf("nil Transport() == %v, want http.DefaultTransport", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Package overlap finds trips that overlap or run back to back, e.g; a
+// conference followed by a holiday, so their projects neither repeat nor
+// contradict each other.
+package overlap
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// Modes of handling adjacent trips.
+const (
+	// Merge adjacent trips into one trip, with one project.
+	Merge = "merge"
+
+	// Suppress the post-trip items of each adjacent trip but the last, and
+	// the pre-trip items of each but the first.
+	Suppress = "suppress"
+)
+
+// A Group is a run of trips, in order of start, each of which starts within
+// the gap of the end of those before it.
+type Group []tripit.Trip
+
+// Find groups ts by adjacency: a trip joins a group if it starts no later
+// than gap after the group ends. Trips on their own are in groups of one.
+func Find(ts []tripit.Trip, gap time.Duration) []Group {
+	sorted := append([]tripit.Trip(nil), ts...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].ActualStartDate.Before(sorted[b].ActualStartDate)
+	})
+
+	var ret []Group
+	var end time.Time
+	for _, t := range sorted {
+		if n := len(ret); n > 0 && !t.ActualStartDate.After(end.Add(gap)) {
+			ret[n-1] = append(ret[n-1], t)
+		} else {
+			ret = append(ret, Group{t})
+		}
+		if len(ret[len(ret)-1]) == 1 || t.ActualEndDate.After(end) {
+			end = t.ActualEndDate
+		}
+	}
+	return ret
+}
+
+// Merged returns one trip spanning g, with the lodging of all of them in
+// order of check-in, named for all of them. It keeps the ID of the first
+// trip with a project in links (trip IDs to projects, as in state.State), so
+// that project carries on as the group changes; or, if none has one, the
+// first trip's ID. It also returns the IDs of the other trips with projects,
+// which are no longer synced.
+func (g Group) Merged(links map[string]string) (tripit.Trip, []string) {
+	ret := g[0]
+	var dropped []string
+	kept := false
+	for _, t := range g {
+		if _, ok := links[t.Id]; !ok {
+			continue
+		}
+		if kept {
+			dropped = append(dropped, t.Id)
+			continue
+		}
+		ret.Id = t.Id
+		kept = true
+	}
+	ret.Lodging = append([]tripit.LodgingObject(nil), ret.Lodging...)
+	names := []string{ret.DisplayName}
+	for _, t := range g[1:] {
+		names = append(names, t.DisplayName)
+		ret.Lodging = append(ret.Lodging, t.Lodging...)
+		if t.ActualEndDate.After(ret.ActualEndDate) {
+			ret.ActualEndDate = t.ActualEndDate
+			ret.EndDate = t.EndDate
+			ret.EndSource = t.EndSource
+		}
+	}
+	tripit.SortLodging(ret.Lodging)
+	ret.DisplayName = strings.Join(names, " + ")
+	return ret, dropped
+}
+
+// Suppress returns whether the pre-trip and post-trip items of the i'th trip
+// in g should be suppressed.
+func (g Group) Suppress(i int) (pre, post bool) {
+	return i > 0, i < len(g)-1
+}
@@ -0,0 +1,154 @@
+package overlap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+func trip(id string, start, end time.Time) tripit.Trip {
+	return tripit.Trip{
+		Id:              id,
+		DisplayName:     "Trip " + id,
+		ActualStartDate: start,
+		ActualEndDate:   end,
+		EndSource:       "source " + id,
+	}
+}
+
+func at(day, hour int) time.Time {
+	return time.Date(2016, 07, day, hour, 00, 00, 00, time.UTC)
+}
+
+func TestFind(t *testing.T) {
+	conference := trip("C", at(10, 9), at(13, 18))
+	holiday := trip("H", at(14, 10), at(20, 18)) // 16 hours after the conference.
+	overlapping := trip("O", at(19, 9), at(22, 18))
+	within := trip("W", at(11, 9), at(12, 18)) // Within the conference.
+	later := trip("L", at(25, 9), at(26, 18))
+
+	cases := []struct {
+		trips []tripit.Trip
+		gap   time.Duration
+		want  [][]string
+	}{
+		{nil, 24 * time.Hour, nil},
+		{[]tripit.Trip{conference}, 24 * time.Hour, [][]string{{"C"}}},
+		{[]tripit.Trip{holiday, conference}, 24 * time.Hour, [][]string{{"C", "H"}}},
+		{[]tripit.Trip{conference, holiday}, 12 * time.Hour, [][]string{{"C"}, {"H"}}},
+		{[]tripit.Trip{conference, holiday}, 16 * time.Hour, [][]string{{"C", "H"}}},
+		{[]tripit.Trip{later, holiday, overlapping}, 0, [][]string{{"H", "O"}, {"L"}}},
+		{[]tripit.Trip{conference, within, holiday}, 24 * time.Hour, [][]string{{"C", "W", "H"}}},
+		{[]tripit.Trip{conference, holiday, overlapping, later}, 3 * 24 * time.Hour, [][]string{{"C", "H", "O", "L"}}},
+	}
+
+	for i, c := range cases {
+		var got [][]string
+		for _, g := range Find(c.trips, c.gap) {
+			var ids []string
+			for _, t := range g {
+				ids = append(ids, t.Id)
+			}
+			got = append(got, ids)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%d: Find() == %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestMerged(t *testing.T) {
+	g := Group{
+		trip("C", at(10, 9), at(20, 18)),
+		trip("W", at(11, 9), at(12, 18)),
+		trip("H", at(14, 10), at(18, 18)),
+	}
+	g[0].Lodging = []tripit.LodgingObject{{Id: "LC"}}
+	g[2].Lodging = []tripit.LodgingObject{{Id: "LH1"}, {Id: "LH2"}}
+	got, _ := g.Merged(nil)
+	if n := len(got.Lodging); n != 3 || got.Lodging[0].Id != "LC" || got.Lodging[2].Id != "LH2" {
+		t.Errorf("Merged() lodging == %v, want LC, LH1, LH2", got.Lodging)
+	}
+	if len(g[0].Lodging) != 1 {
+		t.Errorf("Merged() changed the first trip's lodging to %v", g[0].Lodging)
+	}
+	if got.Id != "C" || got.DisplayName != "Trip C + Trip W + Trip H" {
+		t.Errorf("Merged() == %s %q, want C %q", got.Id, got.DisplayName, "Trip C + Trip W + Trip H")
+	}
+	if !got.ActualStartDate.Equal(at(10, 9)) || !got.ActualEndDate.Equal(at(20, 18)) || got.EndSource != "source C" {
+		t.Errorf("Merged() from %v to %v (%s), want %v to %v (source C)", got.ActualStartDate, got.ActualEndDate, got.EndSource, at(10, 9), at(20, 18))
+	}
+
+	g[2].ActualEndDate = at(22, 18)
+	if got, _ := g.Merged(nil); !got.ActualEndDate.Equal(at(22, 18)) || got.EndSource != "source H" {
+		t.Errorf("Merged() ends %v (%s), want %v (source H)", got.ActualEndDate, got.EndSource, at(22, 18))
+	}
+}
+
+func TestMergedLodging(t *testing.T) {
+	stay := func(id, date string) tripit.LodgingObject {
+		return tripit.LodgingObject{Id: id, StartDateTime: tripit.DateTime{Date: date, Timezone: "Europe/Lisbon"}}
+	}
+	// A holiday within a conference, at another hotel for a few nights.
+	g := Group{
+		trip("C", at(10, 9), at(20, 18)),
+		trip("H", at(14, 10), at(18, 18)),
+	}
+	g[0].Lodging = []tripit.LodgingObject{stay("LC1", "2016-07-10"), stay("LC2", "2016-07-18")}
+	g[1].Lodging = []tripit.LodgingObject{stay("LH", "2016-07-14")}
+
+	var got []string
+	merged, _ := g.Merged(nil)
+	for _, l := range merged.Lodging {
+		got = append(got, l.Id)
+	}
+	if want := []string{"LC1", "LH", "LC2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Merged() lodging == %v, want %v", got, want)
+	}
+}
+
+func TestMergedId(t *testing.T) {
+	conference := trip("C", at(10, 9), at(13, 18))
+	holiday := trip("H", at(14, 10), at(20, 18))
+	earlier := trip("E", at(8, 9), at(9, 18))
+
+	cases := []struct {
+		g           Group
+		links       map[string]string
+		want        string
+		wantDropped []string
+	}{
+		{Group{conference, holiday}, nil, "C", nil},
+		{Group{conference, holiday}, map[string]string{"C": "p1"}, "C", nil},
+		{Group{conference, holiday}, map[string]string{"H": "p1"}, "H", nil},
+		{Group{conference, holiday}, map[string]string{"C": "p1", "H": "p2"}, "C", []string{"H"}},
+		// The group gains an earlier trip; the project carries on.
+		{Group{earlier, conference, holiday}, map[string]string{"C": "p1"}, "C", nil},
+		{Group{earlier, conference, holiday}, map[string]string{"H": "p1", "C": "p2", "E": "p3"}, "E", []string{"C", "H"}},
+	}
+
+	for i, c := range cases {
+		got, dropped := c.g.Merged(c.links)
+		if got.Id != c.want {
+			t.Errorf("%d: Merged(%v) ID == %s, want %s", i, c.links, got.Id, c.want)
+		}
+		if !reflect.DeepEqual(dropped, c.wantDropped) {
+			t.Errorf("%d: Merged(%v) dropped == %v, want %v", i, c.links, dropped, c.wantDropped)
+		}
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	g := make(Group, 3)
+	want := [][2]bool{{false, true}, {true, true}, {true, false}}
+	for i, w := range want {
+		if pre, post := g.Suppress(i); pre != w[0] || post != w[1] {
+			t.Errorf("Suppress(%d) == %v, %v, want %v, %v", i, pre, post, w[0], w[1])
+		}
+	}
+	if pre, post := g[:1].Suppress(0); pre || post {
+		t.Errorf("Suppress(0) of one trip == %v, %v, want false, false", pre, post)
+	}
+}
@@ -0,0 +1,170 @@
+// Package preview shows people what a checklist expands to for a trip.
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/seanrees/tripist/internal/rules"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// Formats for Write.
+const (
+	Text     = "text"
+	Markdown = "markdown"
+	JSON     = "json"
+)
+
+// A Preview is a trip's expanded checklist.
+type Preview struct {
+	// Project is the name of the trip's project.
+	Project string
+
+	Trip tripit.Trip
+
+	// Checklist is the checklists the tasks were expanded from, and why they
+	// were chosen.
+	Checklist rules.Choice
+
+	Tasks []tasks.Task
+
+	// Home is the timezone deadlines are set in. Destination, if set, is
+	// the timezone the trip is in.
+	Home, Destination *time.Location
+}
+
+// Write writes p to w in format.
+func Write(w io.Writer, format string, p Preview) error {
+	switch format {
+	case Text:
+		return writeText(w, p)
+	case Markdown:
+		return writeMarkdown(w, p)
+	case JSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(toJSON(p))
+	}
+	return fmt.Errorf("unknown format %q (want %s, %s or %s)", format, Text, Markdown, JSON)
+}
+
+// due describes when t is due, at home and at the destination.
+func (p Preview) due(t tasks.Task) string {
+	if len(t.Recurrence) > 0 {
+		return fmt.Sprintf("due %s in %s", t.Recurrence, t.RecurrenceZone)
+	}
+	if t.DueDateUTC.IsZero() {
+		return "no due date"
+	}
+	s := "due " + t.DueDateUTC.In(p.Home).Format(tasks.DueFormat)
+	if p.Destination != nil && p.Destination.String() != p.Home.String() {
+		s += fmt.Sprintf(", %s in %s", t.DueDateUTC.In(p.Destination).Format(tasks.DueFormat), p.Destination)
+	}
+	return s
+}
+
+func (p Preview) dates() string {
+	return fmt.Sprintf("%s to %s",
+		p.Trip.ActualStartDate.In(p.Home).Format(tasks.DueFormat),
+		p.Trip.ActualEndDate.In(p.Home).Format(tasks.DueFormat))
+}
+
+func writeText(w io.Writer, p Preview) error {
+	fmt.Fprintf(w, "Project:   %s\n", p.Project)
+	fmt.Fprintf(w, "Trip:      %s (%s), %s\n", p.Trip.DisplayName, p.Trip.Id, p.dates())
+	fmt.Fprintf(w, "Checklist: %s\n\n", p.Checklist)
+	for _, t := range p.Tasks {
+		if _, err := fmt.Fprintf(w, "%s%s (%s; position %d)\n", tasks.TreePrefix(t.Indent), t.Content, p.due(t), t.Position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`)
+
+func writeMarkdown(w io.Writer, p Preview) error {
+	fmt.Fprintf(w, "## %s\n\n", markdownEscaper.Replace(p.Project))
+	fmt.Fprintf(w, "*%s* (%s), %s. Checklist: `%s` (%s).\n\n", markdownEscaper.Replace(p.Trip.DisplayName), p.Trip.Id, p.dates(),
+		strings.Join(p.Checklist.Checklists, "` + `"), markdownEscaper.Replace(p.Checklist.Reason))
+	for _, t := range p.Tasks {
+		indent := strings.Repeat("  ", t.Indent-1)
+		if _, err := fmt.Fprintf(w, "%s- **%s**: %s; position %d\n", indent, markdownEscaper.Replace(t.Content), p.due(t), t.Position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonPreview struct {
+	Project         string     `json:"project"`
+	Trip            jsonTrip   `json:"trip"`
+	Checklists      []string   `json:"checklists"`
+	ChecklistReason string     `json:"checklist_reason"`
+	Tasks           []jsonTask `json:"tasks"`
+}
+
+type jsonTrip struct {
+	Id                  string    `json:"id"`
+	Name                string    `json:"name"`
+	Start               time.Time `json:"start"`
+	End                 time.Time `json:"end"`
+	DestinationTimezone string    `json:"destination_timezone,omitempty"`
+}
+
+type jsonTask struct {
+	Content  string `json:"content"`
+	Indent   int    `json:"indent"`
+	Position int    `json:"position"`
+
+	// Due is in the home timezone, and DueDestination the destination's.
+	Due            *time.Time `json:"due,omitempty"`
+	DueDestination *time.Time `json:"due_destination,omitempty"`
+
+	// Recurrence is set, instead of Due, for tasks recurring in Todoist.
+	Recurrence         string `json:"recurrence,omitempty"`
+	RecurrenceTimezone string `json:"recurrence_timezone,omitempty"`
+
+	Reminders []string `json:"reminders,omitempty"`
+}
+
+func toJSON(p Preview) jsonPreview {
+	ret := jsonPreview{
+		Project: p.Project,
+		Trip: jsonTrip{
+			Id:    p.Trip.Id,
+			Name:  p.Trip.DisplayName,
+			Start: p.Trip.ActualStartDate,
+			End:   p.Trip.ActualEndDate,
+		},
+		Checklists:      p.Checklist.Checklists,
+		ChecklistReason: p.Checklist.Reason,
+		Tasks:           []jsonTask{},
+	}
+	if p.Destination != nil {
+		ret.Trip.DestinationTimezone = p.Destination.String()
+	}
+
+	for _, t := range p.Tasks {
+		jt := jsonTask{Content: t.Content, Indent: t.Indent, Position: t.Position,
+			Recurrence: t.Recurrence, RecurrenceTimezone: t.RecurrenceZone}
+		if !t.DueDateUTC.IsZero() {
+			due := t.DueDateUTC.In(p.Home)
+			jt.Due = &due
+			if p.Destination != nil {
+				dd := t.DueDateUTC.In(p.Destination)
+				jt.DueDestination = &dd
+			}
+		}
+		for _, r := range t.Reminders {
+			jt.Reminders = append(jt.Reminders, r.String())
+		}
+		ret.Tasks = append(ret.Tasks, jt)
+	}
+	return ret
+}
@@ -0,0 +1,142 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/rules"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+func testPreview(t *testing.T) Preview {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Preview{
+		Project: "Trip: Lisbon",
+		Trip: tripit.Trip{
+			Id:              "T1",
+			DisplayName:     "Lisbon",
+			ActualStartDate: time.Date(2016, 07, 15, 10, 00, 00, 00, time.UTC),
+			ActualEndDate:   time.Date(2016, 07, 20, 18, 00, 00, 00, time.UTC),
+		},
+		Checklist: rules.Choice{Checklists: []string{"work.csv", "common.csv"}, Reason: "rule 1 (purpose in [B])"},
+		Tasks: []tasks.Task{
+			{Content: "Pre-trip", Indent: 1, Position: 0, DueDateUTC: time.Date(2016, 07, 14, 23, 00, 00, 00, time.UTC)},
+			{Content: "Apply for *visa*", Indent: 2, Position: 1, DueDateUTC: time.Date(2016, 07, 10, 19, 00, 00, 00, time.UTC),
+				Reminders: []tasks.Reminder{{MinutesBefore: 60}}},
+			{Content: "Unpack", Indent: 1, Position: 2},
+		},
+		Home:        dublin,
+		Destination: lisbon,
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	p := testPreview(t)
+	p.Tasks = append(p.Tasks, tasks.Task{Content: "Take tablet", Indent: 1, Position: 3,
+		Recurrence: "every day at 09:00 starting 2016-07-15 until 2016-07-20", RecurrenceZone: "Europe/Lisbon"})
+
+	var b bytes.Buffer
+	if err := Write(&b, Text, p); err != nil {
+		t.Fatalf("Write() == %v, want nil", err)
+	}
+	want := `Project:   Trip: Lisbon
+Trip:      Lisbon (T1), Fri 15 Jul 2016 11:00 to Wed 20 Jul 2016 19:00
+Checklist: work.csv + common.csv (rule 1 (purpose in [B]))
+
+. Pre-trip (due Fri 15 Jul 2016 00:00, Fri 15 Jul 2016 00:00 in Europe/Lisbon; position 0)
+` + "`" + `--- Apply for *visa* (due Sun 10 Jul 2016 20:00, Sun 10 Jul 2016 20:00 in Europe/Lisbon; position 1)
+. Unpack (no due date; position 2)
+. Take tablet (due every day at 09:00 starting 2016-07-15 until 2016-07-20 in Europe/Lisbon; position 3)
+`
+	if got := b.String(); got != want {
+		t.Errorf("Write(Text) ==\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	p := testPreview(t)
+	p.Destination = p.Home
+
+	var b bytes.Buffer
+	if err := Write(&b, Markdown, p); err != nil {
+		t.Fatalf("Write() == %v, want nil", err)
+	}
+	got := strings.Split(b.String(), "\n")
+	want := []string{
+		"- **Pre-trip**: due Fri 15 Jul 2016 00:00; position 0",
+		`  - **Apply for \*visa\***: due Sun 10 Jul 2016 20:00; position 1`,
+		"- **Unpack**: no due date; position 2",
+	}
+	if len(got) < len(want)+1 {
+		t.Fatalf("Write(Markdown) == %q, want at least %d lines", got, len(want)+1)
+	}
+	got = got[len(got)-len(want)-1 : len(got)-1]
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Write(Markdown) line %d == %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, JSON, testPreview(t)); err != nil {
+		t.Fatalf("Write() == %v, want nil", err)
+	}
+
+	var got struct {
+		Checklists []string `json:"checklists"`
+		Trip       struct {
+			DestinationTimezone string `json:"destination_timezone"`
+		} `json:"trip"`
+		Tasks []struct {
+			Content        string   `json:"content"`
+			Indent         int      `json:"indent"`
+			Due            string   `json:"due"`
+			DueDestination string   `json:"due_destination"`
+			Reminders      []string `json:"reminders"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s) == %v, want nil", b.String(), err)
+	}
+
+	if want := []string{"work.csv", "common.csv"}; !reflect.DeepEqual(got.Checklists, want) {
+		t.Errorf("checklists == %v, want %v", got.Checklists, want)
+	}
+	if got.Trip.DestinationTimezone != "Europe/Lisbon" {
+		t.Errorf("trip.destination_timezone == %q, want Europe/Lisbon", got.Trip.DestinationTimezone)
+	}
+	if len(got.Tasks) != 3 {
+		t.Fatalf("tasks == %v, want 3", got.Tasks)
+	}
+	visa := got.Tasks[1]
+	if visa.Indent != 2 || visa.Due != "2016-07-10T20:00:00+01:00" || visa.DueDestination != "2016-07-10T20:00:00+01:00" {
+		t.Errorf("tasks[1] == %+v, want indent 2 due 2016-07-10T20:00:00+01:00", visa)
+	}
+	if len(visa.Reminders) != 1 || visa.Reminders[0] != "60 minutes before" {
+		t.Errorf("tasks[1].reminders == %v, want [60 minutes before]", visa.Reminders)
+	}
+	if unpack := got.Tasks[2]; len(unpack.Due) > 0 {
+		t.Errorf("tasks[2].due == %q, want none", unpack.Due)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, "yaml", testPreview(t)); err == nil {
+		t.Errorf("Write(yaml) == nil, want error")
+	}
+}
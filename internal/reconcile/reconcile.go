@@ -0,0 +1,135 @@
+// Package reconcile brings projects in a todoist.TaskBackend into line with
+// the projects expanded from trips' checklists.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/seanrees/tripist/internal/logging"
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/todoist"
+)
+
+// What was done with a project.
+const (
+	Created = "created"
+	Updated = "updated"
+	Skipped = "skipped"
+	Failed  = "failed"
+)
+
+// A Plan is a project to reconcile.
+type Plan struct {
+	Project tasks.Project
+
+	// Skipped is the number of checklist items not made into tasks,
+	// because they are past due or not yet within their lead time.
+	Skipped int
+
+	// Err, if set, is why the project could not be planned. The plan
+	// fails without touching the backend.
+	Err error
+}
+
+// Result describes what happened to a plan.
+type Result struct {
+	Action string
+
+	// Tasks added and changed in the backend. Tasks are never removed.
+	Added, Changed int
+
+	// Skipped is copied from the plan.
+	Skipped int
+
+	Err error
+}
+
+// All reconciles plans with b using up to workers goroutines, and returns
+// their results in the same order as plans. Plans not started before ctx
+// is done fail with its error.
+func All(ctx context.Context, b todoist.TaskBackend, plans []Plan, workers int) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(plans) {
+		workers = len(plans)
+	}
+
+	// Each result is written by one worker, so none need locking.
+	results := make([]Result, len(plans))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				ctx := logging.With(ctx, "trip_id", plans[i].Project.Id)
+				results[i] = One(ctx, b, plans[i])
+			}
+		}()
+	}
+	for i := range plans {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
+
+// One reconciles a single plan with b: the project is created if it is
+// missing and updated if not, unless it has no tasks.
+func One(ctx context.Context, b todoist.TaskBackend, p Plan) Result {
+	r, err := one(ctx, b, p)
+	r.Skipped = p.Skipped
+	if err != nil {
+		r.Action = Failed
+		r.Err = err
+	}
+	return r
+}
+
+func one(ctx context.Context, b todoist.TaskBackend, p Plan) (Result, error) {
+	if p.Err != nil {
+		return Result{}, p.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	slog.InfoContext(ctx, "Processing trip", "project", p.Project.Name)
+	if p.Project.Empty() {
+		slog.InfoContext(ctx, "No tasks within their lead time, skipping")
+		return Result{Action: Skipped}, nil
+	}
+
+	rp, found, err := b.LoadProject(ctx, p.Project.Id, p.Project.Name)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not load remote project: %v", err)
+	}
+
+	if !found {
+		if err := b.CreateProject(ctx, p.Project); err != nil {
+			return Result{}, fmt.Errorf("unable to create project: %v", err)
+		}
+		return Result{Action: Created, Added: len(p.Project.Tasks)}, nil
+	}
+
+	diffs := rp.DiffTasks(p.Project)
+	if err := b.UpdateProject(ctx, rp, diffs); err != nil {
+		return Result{}, fmt.Errorf("unable to update project: %v", err)
+	}
+	r := Result{Action: Updated}
+	for _, d := range diffs {
+		switch d.Type {
+		case tasks.Added:
+			r.Added++
+		case tasks.Changed:
+			r.Changed++
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,226 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/todoist"
+)
+
+// fakeBackend keeps projects in memory, keyed by tasks.Project.Id. Projects
+// whose names are in fail cannot be written.
+type fakeBackend struct {
+	mu       sync.Mutex
+	projects map[string]tasks.Project
+	fail     map[string]bool
+
+	// active and peak count operations in flight.
+	active, peak int
+
+	// If wait > 0, operations are held until wait of them are in flight
+	// at once, then ready is closed to release them.
+	wait  int
+	ready chan struct{}
+}
+
+func newFakeBackend(existing ...tasks.Project) *fakeBackend {
+	b := &fakeBackend{projects: make(map[string]tasks.Project), fail: make(map[string]bool)}
+	for _, p := range existing {
+		b.projects[p.Id] = p
+	}
+	return b
+}
+
+// barrier makes the first n operations wait for each other.
+func (b *fakeBackend) barrier(n int) {
+	b.wait = n
+	b.ready = make(chan struct{})
+}
+
+// enter records an operation starting, and waits at the barrier if there is
+// one. It returns a func to call when the operation ends.
+func (b *fakeBackend) enter() func() {
+	b.mu.Lock()
+	b.active++
+	if b.active > b.peak {
+		b.peak = b.active
+	}
+	if b.wait > 0 && b.active == b.wait {
+		b.wait = 0
+		close(b.ready)
+	}
+	ready := b.ready
+	b.mu.Unlock()
+
+	if ready != nil {
+		// Give up eventually, so a missing worker fails the test
+		// rather than hanging it.
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return func() {
+		b.mu.Lock()
+		b.active--
+		b.mu.Unlock()
+	}
+}
+
+func (b *fakeBackend) LoadProject(ctx context.Context, id, name string) (tasks.Project, bool, error) {
+	defer b.enter()()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.projects[id]
+	return p, ok, nil
+}
+
+func (b *fakeBackend) CreateProject(ctx context.Context, p tasks.Project) error {
+	defer b.enter()()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail[p.Name] {
+		return errors.New("write failed")
+	}
+	b.projects[p.Id] = p
+	return nil
+}
+
+func (b *fakeBackend) UpdateProject(ctx context.Context, p tasks.Project, diffs []tasks.Diff) error {
+	defer b.enter()()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail[p.Name] {
+		return errors.New("write failed")
+	}
+	for _, d := range diffs {
+		if d.Type == tasks.Added {
+			p.Tasks = append(p.Tasks, d.Task)
+		}
+	}
+	b.projects[p.Id] = p
+	return nil
+}
+
+func (b *fakeBackend) DeleteProject(ctx context.Context, p tasks.Project) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.projects, p.Id)
+	return nil
+}
+
+func (b *fakeBackend) SetProjectOptions(o todoist.ProjectOptions) {}
+func (b *fakeBackend) SetLinks(l map[string]string)               {}
+func (b *fakeBackend) SetCacheFile(filename string)               {}
+func (b *fakeBackend) SetTimeout(d time.Duration)                 {}
+func (b *fakeBackend) SetTransport(t http.RoundTripper)           {}
+
+var _ todoist.TaskBackend = (*fakeBackend)(nil)
+
+func task(content string) tasks.Task {
+	return tasks.Task{Content: content, Indent: 1}
+}
+
+func TestOne(t *testing.T) {
+	existing := tasks.Project{Id: "t1", Name: "Trip: One", Tasks: []tasks.Task{task("Pack"), task("Unpack")}}
+	planErr := errors.New("bad checklist")
+
+	cases := []struct {
+		name string
+		plan Plan
+		want Result
+	}{{
+		name: "create",
+		plan: Plan{Project: tasks.Project{Id: "t2", Name: "Trip: Two", Tasks: []tasks.Task{task("Pack")}}, Skipped: 2},
+		want: Result{Action: Created, Added: 1, Skipped: 2},
+	}, {
+		name: "update",
+		plan: Plan{Project: tasks.Project{Id: "t1", Name: "Trip: One", Tasks: []tasks.Task{task("Pack"), task("Passport")}}},
+		want: Result{Action: Updated, Added: 1},
+	}, {
+		name: "empty",
+		plan: Plan{Project: tasks.Project{Id: "t3", Name: "Trip: Three"}, Skipped: 4},
+		want: Result{Action: Skipped, Skipped: 4},
+	}, {
+		name: "plan error",
+		plan: Plan{Project: tasks.Project{Id: "t4", Name: "Trip: Four"}, Err: planErr},
+		want: Result{Action: Failed, Err: planErr},
+	}}
+
+	for _, c := range cases {
+		b := newFakeBackend(existing)
+		if got := One(context.Background(), b, c.plan); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("One(%s) == %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	var existing []tasks.Project
+	var plans []Plan
+	var want []Result
+	for i := 0; i < 20; i++ {
+		p := tasks.Project{Id: fmt.Sprintf("t%d", i), Name: fmt.Sprintf("Trip %d", i), Tasks: []tasks.Task{task("Pack"), task("Passport")}}
+		plans = append(plans, Plan{Project: p, Skipped: i})
+
+		switch i % 4 {
+		case 0:
+			want = append(want, Result{Action: Created, Added: 2, Skipped: i})
+		case 1:
+			existing = append(existing, tasks.Project{Id: p.Id, Name: p.Name, Tasks: []tasks.Task{task("Pack")}})
+			want = append(want, Result{Action: Updated, Added: 1, Skipped: i})
+		case 2:
+			plans[i].Project.Tasks = nil
+			want = append(want, Result{Action: Skipped, Skipped: i})
+		case 3:
+			want = append(want, Result{Action: Failed, Skipped: i, Err: errors.New("unable to create project: write failed")})
+		}
+	}
+
+	cases := []struct {
+		workers int
+		want    int // operations at once.
+	}{
+		{0, 1},
+		{1, 1},
+		{4, 4},
+		{50, 15}, // Plans without tasks are skipped without loading.
+	}
+	for _, c := range cases {
+		b := newFakeBackend(existing...)
+		for i := 3; i < len(plans); i += 4 {
+			b.fail[plans[i].Project.Name] = true
+		}
+		b.barrier(c.want)
+
+		got := All(context.Background(), b, plans, c.workers)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("All(%d workers) == %+v, want %+v", c.workers, got, want)
+		}
+		if b.peak != c.want {
+			t.Errorf("All(%d workers) ran %d operations at once, want %d", c.workers, b.peak, c.want)
+		}
+	}
+}
+
+func TestAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	plans := []Plan{{Project: tasks.Project{Id: "t1", Name: "Trip", Tasks: []tasks.Task{task("Pack")}}}}
+	b := newFakeBackend()
+	got := All(ctx, b, plans, 2)
+	if len(got) != 1 || got[0].Action != Failed || !errors.Is(got[0].Err, context.Canceled) {
+		t.Errorf("All() == %+v, want failed with context.Canceled", got)
+	}
+	if len(b.projects) != 0 {
+		t.Errorf("All() created %v after cancellation, want nothing", b.projects)
+	}
+}
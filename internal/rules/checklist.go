@@ -0,0 +1,133 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// Modes of ChecklistRules.
+const (
+	// FirstMatch uses the checklists of the first rule a trip matches.
+	FirstMatch = "first"
+
+	// MergeAll merges the checklists of every rule a trip matches.
+	MergeAll = "merge"
+)
+
+// A ChecklistRule gives the checklists for the trips it matches.
+type ChecklistRule struct {
+	Match
+
+	Checklists []string
+}
+
+// ChecklistRules choose the checklists for each trip, e.g; work.csv for
+// business trips. Trips matching no rule use the profile's checklist.
+type ChecklistRules struct {
+	// Mode is FirstMatch (the default) or MergeAll.
+	Mode string `json:",omitempty"`
+
+	Rules []ChecklistRule
+}
+
+// LoadChecklistRules reads ChecklistRules from filename. Relative paths to
+// checklists are relative to the file's directory.
+func LoadChecklistRules(filename string) (ChecklistRules, error) {
+	var r ChecklistRules
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	switch r.Mode {
+	case "":
+		r.Mode = FirstMatch
+	case FirstMatch, MergeAll:
+	default:
+		return r, fmt.Errorf("%s: unknown mode %q (want %s or %s)", filename, r.Mode, FirstMatch, MergeAll)
+	}
+
+	dir := filepath.Dir(filename)
+	for i, cr := range r.Rules {
+		if len(cr.Checklists) == 0 {
+			return r, fmt.Errorf("%s: rule %d has no checklists", filename, i+1)
+		}
+		for j, c := range cr.Checklists {
+			if !filepath.IsAbs(c) {
+				cr.Checklists[j] = filepath.Join(dir, c)
+			}
+		}
+	}
+	return r, nil
+}
+
+// A Choice is the checklists chosen for a trip, and why.
+type Choice struct {
+	Checklists []string
+
+	// Reason describes what chose the checklists, e.g; "rule 1 (purpose
+	// in [B])".
+	Reason string
+}
+
+func (c Choice) String() string {
+	return fmt.Sprintf("%s (%s)", strings.Join(c.Checklists, " + "), c.Reason)
+}
+
+// Choose returns the checklists for t, or def if t matches no rule.
+func (r ChecklistRules) Choose(t tripit.Trip, def string) Choice {
+	var ret Choice
+	var matched []string
+	seen := make(map[string]bool)
+	for i, cr := range r.Rules {
+		if !cr.Matches(t) {
+			continue
+		}
+		matched = append(matched, fmt.Sprintf("rule %d (%s)", i+1, cr.Match))
+		for _, c := range cr.Checklists {
+			if !seen[c] {
+				seen[c] = true
+				ret.Checklists = append(ret.Checklists, c)
+			}
+		}
+		if r.Mode != MergeAll {
+			break
+		}
+	}
+
+	if len(matched) == 0 {
+		return Choice{Checklists: []string{def}, Reason: "no rule matched"}
+	}
+	ret.Reason = strings.Join(matched, ", ")
+	return ret
+}
+
+// Combine returns one Choice holding the checklists, and reasons, of cs,
+// each once, in order.
+func Combine(cs ...Choice) Choice {
+	var ret Choice
+	var reasons []string
+	seenChecklists, seenReasons := make(map[string]bool), make(map[string]bool)
+	for _, c := range cs {
+		for _, cl := range c.Checklists {
+			if !seenChecklists[cl] {
+				seenChecklists[cl] = true
+				ret.Checklists = append(ret.Checklists, cl)
+			}
+		}
+		if !seenReasons[c.Reason] {
+			seenReasons[c.Reason] = true
+			reasons = append(reasons, c.Reason)
+		}
+	}
+	ret.Reason = strings.Join(reasons, "; ")
+	return ret
+}
@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+func TestLoadChecklistRules(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "rules.json")
+
+	cases := []struct {
+		content string
+		want    ChecklistRules
+		wantErr bool
+	}{
+		{
+			content: `{"Rules": [{"Purpose": ["B"], "Checklists": ["work.csv", "/abs/common.csv"]}]}`,
+			want: ChecklistRules{Mode: FirstMatch, Rules: []ChecklistRule{{
+				Match:      Match{Purpose: []string{"B"}},
+				Checklists: []string{filepath.Join(dir, "work.csv"), "/abs/common.csv"},
+			}}},
+		},
+		{
+			content: `{"Mode": "merge", "Rules": []}`,
+			want:    ChecklistRules{Mode: MergeAll, Rules: []ChecklistRule{}},
+		},
+		{content: `{"Mode": "all"}`, wantErr: true},
+		{content: `{"Rules": [{"Purpose": ["B"]}]}`, wantErr: true},
+		{content: `{"Rules": [{"Name": "(", "Checklists": ["a.csv"]}]}`, wantErr: true},
+		{content: `{"Rules": `, wantErr: true},
+	}
+
+	for i, c := range cases {
+		if err := os.WriteFile(fn, []byte(c.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadChecklistRules(fn)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("%d: LoadChecklistRules() error == %v, want error %v", i, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%d: LoadChecklistRules() == %+v, want %+v", i, got, c.want)
+		}
+	}
+
+	if _, err := LoadChecklistRules(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("LoadChecklistRules(missing) == nil, want error")
+	}
+}
+
+func TestChoose(t *testing.T) {
+	lisbon := trip("T0", "Lisbon", "L", "PT", 5, false, true)
+	berlin := trip("T1", "Berlin", "B", "DE", 2, false, true)
+	cork := trip("T2", "Cork", "B", "IE", 0, false, true)
+	rules := `{"Rules": [
+		{"MaxDays": 1, "Checklists": ["day.csv"]},
+		{"Purpose": ["B"], "Checklists": ["work.csv", "common.csv"]},
+		{"Country": ["PT", "ES"], "Checklists": ["family.csv", "common.csv"]}
+	]}`
+
+	cases := []struct {
+		mode string
+		want []Choice
+	}{
+		{
+			mode: FirstMatch,
+			want: []Choice{
+				{[]string{"family.csv", "common.csv"}, "rule 3 (country in [PT ES])"},
+				{[]string{"work.csv", "common.csv"}, "rule 2 (purpose in [B])"},
+				{[]string{"day.csv"}, "rule 1 (at most 1 days)"},
+			},
+		},
+		{
+			mode: MergeAll,
+			want: []Choice{
+				{[]string{"family.csv", "common.csv"}, "rule 3 (country in [PT ES])"},
+				{[]string{"work.csv", "common.csv"}, "rule 2 (purpose in [B])"},
+				{[]string{"day.csv", "work.csv", "common.csv"}, "rule 1 (at most 1 days), rule 2 (purpose in [B])"},
+			},
+		},
+	}
+
+	trips := []tripit.Trip{lisbon, berlin, cork}
+	for _, c := range cases {
+		var r ChecklistRules
+		if err := json.Unmarshal([]byte(rules), &r); err != nil {
+			t.Fatal(err)
+		}
+		r.Mode = c.mode
+
+		for i, tr := range trips {
+			if got := r.Choose(tr, "checklist.csv"); !reflect.DeepEqual(got, c.want[i]) {
+				t.Errorf("%s: Choose(%s) == %v, want %v", c.mode, tr.DisplayName, got, c.want[i])
+			}
+		}
+	}
+
+	var none ChecklistRules
+	want := Choice{[]string{"checklist.csv"}, "no rule matched"}
+	if got := none.Choose(lisbon, "checklist.csv"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Choose() without rules == %v, want %v", got, want)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	got := Combine(
+		Choice{[]string{"work.csv", "common.csv"}, "rule 1 (purpose in [B])"},
+		Choice{[]string{"family.csv", "common.csv"}, "rule 2 (country in [PT])"},
+		Choice{[]string{"work.csv"}, "rule 1 (purpose in [B])"},
+	)
+	want := Choice{[]string{"work.csv", "common.csv", "family.csv"}, "rule 1 (purpose in [B]); rule 2 (country in [PT])"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Combine() == %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,232 @@
+// Package rules decides which trips are synced, and how, from predicates on
+// their TripIt fields.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// A Regexp is a regular expression kept in configuration as a string.
+type Regexp struct {
+	*regexp.Regexp
+}
+
+func (r *Regexp) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	r.Regexp = re
+	return nil
+}
+
+func (r Regexp) MarshalJSON() ([]byte, error) {
+	if r.Regexp == nil {
+		return json.Marshal("")
+	}
+	return json.Marshal(r.String())
+}
+
+// A Match is a predicate on trips. A trip matches if it matches every field
+// that is set; the zero Match matches every trip.
+type Match struct {
+	// Purpose is any of these purpose type codes, e.g; B, in any case.
+	Purpose []string `json:",omitempty"`
+
+	// Private and Traveler are the trip's is_private and is_traveler.
+	Private  *bool `json:",omitempty"`
+	Traveler *bool `json:",omitempty"`
+
+	// Name matches the trip's name.
+	Name *Regexp `json:",omitempty"`
+
+	// Country is any of these countries of the trip's primary location, as
+	// TripIt gives them (e.g; PT), in any case.
+	Country []string `json:",omitempty"`
+
+	// Location matches the trip's primary location, e.g; "Lisbon, Portugal".
+	Location *Regexp `json:",omitempty"`
+
+	// MinDays and MaxDays bound how long the trip lasts, in days, from its
+	// actual start to its actual end.
+	MinDays float64 `json:",omitempty"`
+	MaxDays float64 `json:",omitempty"`
+}
+
+// Matches returns whether t matches m.
+func (m Match) Matches(t tripit.Trip) bool {
+	if len(m.Purpose) > 0 && !anyFold(m.Purpose, t.TripPurposes.PurposeTypeCode) {
+		return false
+	}
+	if m.Private != nil && *m.Private != t.IsPrivate {
+		return false
+	}
+	if m.Traveler != nil && *m.Traveler != t.IsTraveler {
+		return false
+	}
+	if m.Name != nil && m.Name.Regexp != nil && !m.Name.MatchString(t.DisplayName) {
+		return false
+	}
+	if len(m.Country) > 0 && !anyFold(m.Country, t.PrimaryLocationAddress.Country) {
+		return false
+	}
+	if m.Location != nil && m.Location.Regexp != nil && !m.Location.MatchString(t.PrimaryLocation) {
+		return false
+	}
+	days := t.ActualEndDate.Sub(t.ActualStartDate).Hours() / 24
+	if m.MinDays > 0 && days < m.MinDays {
+		return false
+	}
+	if m.MaxDays > 0 && days > m.MaxDays {
+		return false
+	}
+	return true
+}
+
+func anyFold(ss []string, s string) bool {
+	for _, x := range ss {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes m, for logs.
+func (m Match) String() string {
+	var parts []string
+	if len(m.Purpose) > 0 {
+		parts = append(parts, fmt.Sprintf("purpose in %v", m.Purpose))
+	}
+	if m.Private != nil {
+		parts = append(parts, fmt.Sprintf("private is %v", *m.Private))
+	}
+	if m.Traveler != nil {
+		parts = append(parts, fmt.Sprintf("traveler is %v", *m.Traveler))
+	}
+	if m.Name != nil && m.Name.Regexp != nil {
+		parts = append(parts, fmt.Sprintf("name matches %q", m.Name.String()))
+	}
+	if len(m.Country) > 0 {
+		parts = append(parts, fmt.Sprintf("country in %v", m.Country))
+	}
+	if m.Location != nil && m.Location.Regexp != nil {
+		parts = append(parts, fmt.Sprintf("location matches %q", m.Location.String()))
+	}
+	if m.MinDays > 0 {
+		parts = append(parts, fmt.Sprintf("at least %v days", m.MinDays))
+	}
+	if m.MaxDays > 0 {
+		parts = append(parts, fmt.Sprintf("at most %v days", m.MaxDays))
+	}
+	if len(parts) == 0 {
+		return "any trip"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// An Override changes how one trip is synced.
+type Override struct {
+	// Skip the trip.
+	Skip bool `json:",omitempty"`
+
+	// Checklist, if set, is expanded for the trip instead of the profile's.
+	Checklist string `json:",omitempty"`
+}
+
+// Rules choose the trips to sync. Trips are synced unless their override
+// skips them, they match an Exclude rule, or there are Include rules and
+// they match none.
+type Rules struct {
+	Include []Match `json:",omitempty"`
+	Exclude []Match `json:",omitempty"`
+
+	// Overrides by trip ID.
+	Overrides map[string]Override `json:",omitempty"`
+}
+
+// Skip returns why t should not be synced, or "" if it should.
+func (r Rules) Skip(t tripit.Trip) string {
+	if r.Overrides[t.Id].Skip {
+		return "skipped by its override"
+	}
+	for i, m := range r.Exclude {
+		if m.Matches(t) {
+			return fmt.Sprintf("matches exclude rule %d (%s)", i+1, m)
+		}
+	}
+	if len(r.Include) == 0 {
+		return ""
+	}
+	for _, m := range r.Include {
+		if m.Matches(t) {
+			return ""
+		}
+	}
+	return "matches no include rule"
+}
+
+// Checklist returns the checklist for t: its override's, or def.
+func (r Rules) Checklist(t tripit.Trip, def string) string {
+	if c := r.Overrides[t.Id].Checklist; len(c) > 0 {
+		return c
+	}
+	return def
+}
+
+// Clone returns a copy of r that shares nothing with it, so either may be
+// changed (e.g; by json.Unmarshal) without affecting the other.
+func (r Rules) Clone() Rules {
+	ret := Rules{
+		Include: cloneMatches(r.Include),
+		Exclude: cloneMatches(r.Exclude),
+	}
+	if r.Overrides != nil {
+		ret.Overrides = make(map[string]Override, len(r.Overrides))
+		for k, v := range r.Overrides {
+			ret.Overrides[k] = v
+		}
+	}
+	return ret
+}
+
+func cloneMatches(ms []Match) []Match {
+	if ms == nil {
+		return nil
+	}
+	ret := make([]Match, len(ms))
+	for i, m := range ms {
+		ret[i] = m.clone()
+	}
+	return ret
+}
+
+func (m Match) clone() Match {
+	ret := m
+	ret.Purpose = append([]string(nil), m.Purpose...)
+	ret.Country = append([]string(nil), m.Country...)
+	if m.Private != nil {
+		b := *m.Private
+		ret.Private = &b
+	}
+	if m.Traveler != nil {
+		b := *m.Traveler
+		ret.Traveler = &b
+	}
+	if m.Name != nil {
+		ret.Name = &Regexp{m.Name.Regexp}
+	}
+	if m.Location != nil {
+		ret.Location = &Regexp{m.Location.Regexp}
+	}
+	return ret
+}
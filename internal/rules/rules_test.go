@@ -0,0 +1,163 @@
+package rules
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+func trip(id, name, purpose, country string, days int, private, traveler bool) tripit.Trip {
+	start := time.Date(2016, 07, 15, 00, 00, 00, 00, time.UTC)
+	return tripit.Trip{
+		Id:                     id,
+		DisplayName:            name,
+		IsPrivate:              private,
+		IsTraveler:             traveler,
+		PrimaryLocationAddress: tripit.Address{Country: country},
+		TripPurposes:           tripit.TripPurpose{PurposeTypeCode: purpose},
+		ActualStartDate:        start,
+		ActualEndDate:          start.AddDate(0, 0, days),
+	}
+}
+
+func parseRules(t *testing.T, s string) Rules {
+	var r Rules
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		t.Fatalf("json.Unmarshal(%s) == %v, want nil", s, err)
+	}
+	return r
+}
+
+func TestMatch(t *testing.T) {
+	lisbon := trip("T0", "Lisbon holiday", "L", "PT", 5, false, true)
+	lisbon.PrimaryLocation = "Lisbon, Portugal"
+
+	cases := []struct {
+		match string
+		want  bool
+	}{
+		{`{}`, true},
+		{`{"Purpose": ["b", "l"]}`, true},
+		{`{"Purpose": ["B"]}`, false},
+		{`{"Private": false}`, true},
+		{`{"Private": true}`, false},
+		{`{"Traveler": false}`, false},
+		{`{"Name": "(?i)HOLIDAY$"}`, true},
+		{`{"Name": "^Berlin"}`, false},
+		{`{"Country": ["pt"]}`, true},
+		{`{"Country": ["DE"]}`, false},
+		{`{"MinDays": 5}`, true},
+		{`{"MinDays": 5.5}`, false},
+		{`{"MaxDays": 5}`, true},
+		{`{"MaxDays": 1}`, false},
+		{`{"Location": "Portugal$"}`, true},
+		{`{"Location": "Spain"}`, false},
+		{`{"Purpose": ["L"], "Country": ["DE"]}`, false},
+	}
+
+	for _, c := range cases {
+		var m Match
+		if err := json.Unmarshal([]byte(c.match), &m); err != nil {
+			t.Fatalf("json.Unmarshal(%s) == %v, want nil", c.match, err)
+		}
+		if got := m.Matches(lisbon); got != c.want {
+			t.Errorf("%s.Matches(%v) == %v, want %v", c.match, lisbon.DisplayName, got, c.want)
+		}
+	}
+}
+
+func TestMatchBadRegexp(t *testing.T) {
+	var m Match
+	if err := json.Unmarshal([]byte(`{"Name": "("}`), &m); err == nil {
+		t.Errorf("json.Unmarshal(bad regexp) == nil, want error")
+	}
+}
+
+func TestSkip(t *testing.T) {
+	trips := []tripit.Trip{
+		trip("T0", "Lisbon", "L", "PT", 5, false, true),
+		trip("T1", "Berlin", "B", "DE", 2, false, true),
+		trip("T2", "Alex in Paris", "B", "FR", 3, false, false),
+		trip("T3", "Secret", "L", "IE", 1, true, true),
+	}
+
+	cases := []struct {
+		rules string
+		want  []string
+	}{
+		{`{}`, []string{"", "", "", ""}},
+		{
+			`{"Exclude": [{"Traveler": false}, {"Private": true}]}`,
+			[]string{"", "", "matches exclude rule 1 (traveler is false)", "matches exclude rule 2 (private is true)"},
+		},
+		{
+			`{"Include": [{"Purpose": ["B"], "MinDays": 3}, {"Country": ["PT"]}]}`,
+			[]string{"", "matches no include rule", "", "matches no include rule"},
+		},
+		{
+			`{"Include": [{"Purpose": ["L"]}], "Exclude": [{"Private": true}], "Overrides": {"T0": {"Skip": true}}}`,
+			[]string{"skipped by its override", "matches no include rule", "matches no include rule", "matches exclude rule 1 (private is true)"},
+		},
+	}
+
+	for _, c := range cases {
+		r := parseRules(t, c.rules)
+		var got []string
+		for _, tr := range trips {
+			got = append(got, r.Skip(tr))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s.Skip() == %q, want %q", c.rules, got, c.want)
+		}
+	}
+}
+
+func TestChecklist(t *testing.T) {
+	r := parseRules(t, `{"Overrides": {"T0": {"Checklist": "ski.csv"}, "T1": {"Skip": true}}}`)
+
+	cases := []struct {
+		id, want string
+	}{
+		{"T0", "ski.csv"},
+		{"T1", "checklist.csv"},
+		{"T2", "checklist.csv"},
+	}
+	for _, c := range cases {
+		if got := r.Checklist(tripit.Trip{Id: c.id}, "checklist.csv"); got != c.want {
+			t.Errorf("Checklist(%s) == %q, want %q", c.id, got, c.want)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	r := parseRules(t, `{"Include": [{"Purpose": ["B"]}], "Overrides": {"T0": {"Skip": true}}}`)
+	c := r.Clone()
+	if err := json.Unmarshal([]byte(`{"Include": [{"Purpose": ["L"]}], "Overrides": {"T1": {"Skip": true}}}`), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := parseRules(t, `{"Include": [{"Purpose": ["B"]}], "Overrides": {"T0": {"Skip": true}}}`)
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("Clone() changed with its clone: %+v, want %+v", r, want)
+	}
+	if len(c.Overrides) != 2 {
+		t.Errorf("clone overrides == %v, want T0 and T1", c.Overrides)
+	}
+}
+
+func TestRegexpRoundTrip(t *testing.T) {
+	m := Match{Name: &Regexp{}}
+	if err := json.Unmarshal([]byte(`"^Lis"`), m.Name); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("json.Marshal() == %v, want nil", err)
+	}
+	if got, want := string(b), `{"Name":"^Lis"}`; got != want {
+		t.Errorf("json.Marshal() == %s, want %s", got, want)
+	}
+}
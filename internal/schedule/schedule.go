@@ -0,0 +1,42 @@
+// Package schedule decides when a long-running tripist syncs next.
+package schedule
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Policy says how often to sync.
+type Policy struct {
+	// Interval between successful syncs.
+	Interval time.Duration
+
+	// Jitter is the most added to each delay, so that many instances don't
+	// all call TripIt and Todoist at once.
+	Jitter time.Duration
+
+	// MinRetry is the delay after the first failure. It doubles with each
+	// further failure, up to Interval.
+	MinRetry time.Duration
+}
+
+// Next returns how long to wait before the next sync, after failures
+// consecutive failed syncs. r supplies the jitter, and may be nil if there
+// is none.
+func (p Policy) Next(failures int, r *rand.Rand) time.Duration {
+	d := p.Interval
+	if failures > 0 && p.MinRetry > 0 {
+		d = p.MinRetry
+		for i := 1; i < failures && d < p.Interval; i++ {
+			d *= 2
+		}
+		if d > p.Interval {
+			d = p.Interval
+		}
+	}
+
+	if p.Jitter > 0 {
+		d += time.Duration(r.Int63n(int64(p.Jitter)))
+	}
+	return d
+}
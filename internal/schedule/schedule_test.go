@@ -0,0 +1,82 @@
+package schedule
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	p := Policy{Interval: 24 * time.Hour, MinRetry: 5 * time.Minute}
+
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 24 * time.Hour},
+		{failures: 1, want: 5 * time.Minute},
+		{failures: 2, want: 10 * time.Minute},
+		{failures: 4, want: 40 * time.Minute},
+		{failures: 9, want: 1280 * time.Minute},
+		{failures: 10, want: 24 * time.Hour},
+		{failures: 100, want: 24 * time.Hour},
+	}
+
+	for _, c := range cases {
+		if got := p.Next(c.failures, nil); got != c.want {
+			t.Errorf("Next(%d) == %v, want %v", c.failures, got, c.want)
+		}
+	}
+}
+
+func TestNextJitter(t *testing.T) {
+	p := Policy{Interval: time.Hour, Jitter: 10 * time.Minute}
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		if got := p.Next(0, r); got < time.Hour || got >= time.Hour+10*time.Minute {
+			t.Fatalf("Next(0) == %v, want within [1h, 1h10m)", got)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	if err := os.WriteFile(a, []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWatcher(a, b)
+	if got := w.Changed(); len(got) != 0 {
+		t.Errorf("Changed() with no changes == %v", got)
+	}
+
+	if err := os.WriteFile(a, []byte("longer"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got := w.Changed()
+	if len(got) == 2 && got[0] > got[1] {
+		got[0], got[1] = got[1], got[0]
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed() == %v, want %v", got, want)
+	}
+
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Changed(), []string{b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed() after remove == %v, want %v", got, want)
+	}
+	if got := w.Changed(); len(got) != 0 {
+		t.Errorf("Changed() again == %v, want none", got)
+	}
+}
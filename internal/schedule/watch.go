@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"os"
+	"time"
+)
+
+// fileState is what a Watcher notices changing.
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func stat(filename string) fileState {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// A Watcher notices when files are created, changed or removed. It polls,
+// so it works everywhere, at the cost of noticing only when asked.
+type Watcher struct {
+	files map[string]fileState
+}
+
+// NewWatcher returns a Watcher for files as they are now.
+func NewWatcher(files ...string) *Watcher {
+	w := &Watcher{}
+	w.Watch(files...)
+	return w
+}
+
+// Watch replaces the files being watched, as they are now.
+func (w *Watcher) Watch(files ...string) {
+	w.files = make(map[string]fileState)
+	for _, f := range files {
+		w.files[f] = stat(f)
+	}
+}
+
+// Changed returns the files that changed since the last call to Changed or
+// Watch.
+func (w *Watcher) Changed() []string {
+	var changed []string
+	for f, was := range w.files {
+		if now := stat(f); now != was {
+			changed = append(changed, f)
+			w.files[f] = now
+		}
+	}
+	return changed
+}
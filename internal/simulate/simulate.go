@@ -0,0 +1,73 @@
+// Package simulate steps a trip's project through time, offline, to show how
+// a checklist plays out before a real trip does.
+package simulate
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
+)
+
+// Options control a simulation.
+type Options struct {
+	// From and To bound the simulated syncs, which are a day apart. From's
+	// Location is the timezone deadlines are set in.
+	From, To time.Time
+
+	// Lead is given to tasks.Expand, for items without a lead time.
+	Lead time.Duration
+}
+
+// A Step is one simulated sync.
+type Step struct {
+	Now time.Time
+
+	// Diffs made to the project by this sync.
+	Diffs []tasks.Diff
+
+	// Tasks in the project after this sync.
+	Tasks []tasks.Task
+}
+
+// Run simulates syncing the project for trip t, expanded from cl, at the
+// same time each day from From to To. As with a real sync, tasks are added
+// and changed but never removed.
+func Run(ctx context.Context, cl []tasks.ChecklistItem, t tasks.Trip, o Options) []Step {
+	var steps []Step
+	var project tasks.Project
+	for now := o.From; !now.After(o.To); now = now.AddDate(0, 0, 1) {
+		want := tasks.Project{Tasks: tasks.Expand(ctx, cl, t, now, o.Lead)}
+
+		var diffs []tasks.Diff
+		for _, d := range project.DiffTasks(want) {
+			if d.Type != tasks.Removed {
+				diffs = append(diffs, d)
+			}
+		}
+		project.Tasks = apply(project.Tasks, diffs)
+
+		steps = append(steps, Step{Now: now, Diffs: diffs, Tasks: project.Tasks})
+	}
+	return steps
+}
+
+// apply returns ts with diffs applied, in checklist order.
+func apply(ts []tasks.Task, diffs []tasks.Diff) []tasks.Task {
+	ret := append([]tasks.Task(nil), ts...)
+	for _, d := range diffs {
+		switch d.Type {
+		case tasks.Added:
+			ret = append(ret, d.Task)
+		case tasks.Changed:
+			for i := range ret {
+				if ret[i].Content == d.Task.Content {
+					ret[i] = d.Task
+				}
+			}
+		}
+	}
+	sort.SliceStable(ret, func(a, b int) bool { return ret[a].Position < ret[b].Position })
+	return ret
+}
@@ -0,0 +1,63 @@
+package simulate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
+)
+
+func TestRun(t *testing.T) {
+	start := time.Date(2016, 07, 15, 10, 00, 00, 00, time.UTC)
+	end := time.Date(2016, 07, 17, 18, 00, 00, 00, time.UTC)
+	cl := []tasks.ChecklistItem{
+		{Template: "Pre-trip", Indent: 1, Due: "1 day before start"},
+		{Template: "Visa", Indent: 2, Due: "3 days before start", Lead: 7 * 24 * time.Hour},
+		{Template: "Pack", Indent: 2, Due: "1 day before start"},
+		{Template: "Unpack", Indent: 1, Due: "1 day after end"},
+	}
+
+	steps := Run(context.Background(), cl, tasks.Trip{Start: start, End: end}, Options{
+		From: time.Date(2016, 07, 5, 9, 00, 00, 00, time.UTC),
+		To:   time.Date(2016, 07, 20, 9, 00, 00, 00, time.UTC),
+		Lead: 2 * 24 * time.Hour,
+	})
+
+	if got, want := len(steps), 16; got != want {
+		t.Fatalf("Run() == %d steps, want %d", got, want)
+	}
+
+	// The day each task is added, counted from From.
+	added := make(map[string]int)
+	for n, s := range steps {
+		for _, d := range s.Diffs {
+			if d.Type != tasks.Added {
+				t.Errorf("Run() step %d diff %v, want only additions", n, d)
+			}
+			added[d.Task.Content] = n
+		}
+	}
+	want := map[string]int{
+		"Pre-trip": 1, // With its first child, Visa.
+		"Visa":     1,
+		"Pack":     8,
+		"Unpack":   12,
+	}
+	if !reflect.DeepEqual(added, want) {
+		t.Errorf("Run() added tasks on days %v, want %v", added, want)
+	}
+
+	// Tasks past due are kept, in checklist order.
+	var got []string
+	for _, t := range steps[len(steps)-1].Tasks {
+		got = append(got, t.Content)
+	}
+	if want := []string{"Pre-trip", "Visa", "Pack", "Unpack"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run() last step tasks == %v, want %v", got, want)
+	}
+	if len(steps[0].Tasks) != 0 {
+		t.Errorf("Run() first step tasks == %v, want none", steps[0].Tasks)
+	}
+}
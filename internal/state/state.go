@@ -0,0 +1,61 @@
+// Package state persists information between runs of the programme.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type State struct {
+	// Projects maps the source of a project (e.g; a TripIt trip ID) to the
+	// Todoist project ID created for it.
+	Projects map[string]string
+}
+
+// Read loads state from filename. A missing file is not an error; it yields
+// empty state.
+func Read(filename string) (State, error) {
+	st := State{Projects: make(map[string]string)}
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+
+	if err := json.Unmarshal(b, &st); err != nil {
+		return st, err
+	}
+	if st.Projects == nil {
+		st.Projects = make(map[string]string)
+	}
+	return st, nil
+}
+
+// Write saves state to filename. The file is replaced atomically so an
+// interrupted run cannot leave it truncated.
+func Write(st State, filename string) error {
+	b, err := json.MarshalIndent(st, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,32 @@
+package state
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "state.json")
+
+	st, err := Read(fn)
+	if err != nil {
+		t.Fatalf("Read(missing) == error (%v), want no error", err)
+	}
+	if len(st.Projects) != 0 {
+		t.Errorf("Read(missing).Projects == %v, want empty", st.Projects)
+	}
+
+	st.Projects["T1"] = "P1"
+	if err := Write(st, fn); err != nil {
+		t.Fatalf("Write() == error (%v), want no error", err)
+	}
+
+	got, err := Read(fn)
+	if err != nil {
+		t.Fatalf("Read() == error (%v), want no error", err)
+	}
+	if !reflect.DeepEqual(got, st) {
+		t.Errorf("Read() == %v, want %v", got, st)
+	}
+}
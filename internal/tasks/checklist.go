@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type ChecklistItem struct {
@@ -15,8 +16,32 @@ type ChecklistItem struct {
 	Indent int
 
 	Due string
+
+	// Reminders for the task, e.g; "30 minutes before" (relative to the due
+	// date) or "1 day before start" (absolute, resolved against the trip).
+	Reminders []string
+
+	// Lead is how long before it is due the task is created. If zero, the
+	// lead given to Expand is used.
+	Lead time.Duration
+
+	// Recur is how an item due every day (or night) of the trip is
+	// expanded: RecurTasks (the default) or RecurTodoist.
+	Recur string
 }
 
+// Ways of expanding items due every day (or night) of a trip.
+const (
+	// RecurTasks expands an item into a task for each day.
+	RecurTasks = "tasks"
+
+	// RecurTodoist expands an item into one task, recurring in Todoist.
+	RecurTodoist = "todoist"
+)
+
+// maxIndent is the deepest indent a checklist item may have.
+const maxIndent = 4
+
 func Load(templateFilename string) ([]ChecklistItem, error) {
 	f, err := os.Open(templateFilename)
 	if err != nil {
@@ -29,6 +54,8 @@ func load(ior io.Reader) ([]ChecklistItem, error) {
 	var errors []string
 	var ret []ChecklistItem
 	r := csv.NewReader(ior)
+	// The options column is optional, so records vary in length.
+	r.FieldsPerRecord = -1
 	for l := 1; ; l++ {
 		rec, err := r.Read()
 		if err == io.EOF {
@@ -51,16 +78,25 @@ func load(ior io.Reader) ([]ChecklistItem, error) {
 			errors = append(errors, fmt.Sprintf("line %d: %v", l, err))
 			continue
 		}
-		if i < 1 || i > 4 {
-			errors = append(errors, fmt.Sprintf("line %d: indent out of range %d [1-4]", l, i))
+		if i < 1 || i > maxIndent {
+			errors = append(errors, fmt.Sprintf("line %d: indent out of range %d [1-%d]", l, i, maxIndent))
 			continue
 		}
 
-		ret = append(ret, ChecklistItem{
+		item := ChecklistItem{
 			Template: rec[0],
 			Indent:   i,
 			Due:      rec[2],
-		})
+		}
+
+		if len(rec) > 3 {
+			if err := parseOptions(rec[3], &item); err != nil {
+				errors = append(errors, fmt.Sprintf("line %d: %v", l, err))
+				continue
+			}
+		}
+
+		ret = append(ret, item)
 	}
 
 	if len(errors) > 0 {
@@ -68,3 +104,133 @@ func load(ior io.Reader) ([]ChecklistItem, error) {
 	}
 	return ret, nil
 }
+
+// parseOptions parses the optional fourth column of a checklist line. Options
+// are semicolon separated key=value pairs, e.g; "remind=30 minutes; remind=1 day
+// before start; lead=30 days; recur=todoist". The remind key may be repeated.
+func parseOptions(s string, item *ChecklistItem) error {
+	for _, o := range strings.Split(s, ";") {
+		o = strings.TrimSpace(o)
+		if len(o) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("option %q not in key=value form", o)
+		}
+		k, v := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+
+		switch k {
+		case "remind":
+			if _, err := parseReminder(v); err != nil {
+				return err
+			}
+			item.Reminders = append(item.Reminders, v)
+
+		case "lead":
+			d, err := parseLead(v)
+			if err != nil {
+				return err
+			}
+			item.Lead = d
+
+		case "recur":
+			if v != RecurTasks && v != RecurTodoist {
+				return fmt.Errorf("unknown recur %q (want %s or %s)", v, RecurTasks, RecurTodoist)
+			}
+			item.Recur = v
+
+		default:
+			return fmt.Errorf("unknown option %q", k)
+		}
+	}
+	return nil
+}
+
+// parseLead parses a humanised lead time, e.g; "30 days" or "12 hours".
+func parseLead(s string) (time.Duration, error) {
+	parts := strings.Fields(strings.ToLower(s))
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("lead time %q not in \"<count> <unit>\" form", s)
+	}
+	d, err := parseDuration(parts[0], parts[1])
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("lead time %q must be positive", s)
+	}
+	return d, nil
+}
+
+// Merge combines checklists into one, in order. An item repeated in a later
+// checklist (the same template under the same parents) is dropped, and its
+// children are added to those of the earlier item.
+func Merge(cls ...[]ChecklistItem) []ChecklistItem {
+	type node struct {
+		item     ChecklistItem
+		children []*node
+	}
+	root := &node{}
+
+	for _, cl := range cls {
+		parents := []*node{root}
+		for _, item := range cl {
+			for len(parents) > 1 && parents[len(parents)-1].item.Indent >= item.Indent {
+				parents = parents[:len(parents)-1]
+			}
+			p := parents[len(parents)-1]
+
+			var n *node
+			for _, c := range p.children {
+				if c.item.Template == item.Template {
+					n = c
+					break
+				}
+			}
+			if n == nil {
+				n = &node{item: item}
+				p.children = append(p.children, n)
+			}
+			parents = append(parents, n)
+		}
+	}
+
+	var ret []ChecklistItem
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, c := range n.children {
+			ret = append(ret, c.item)
+			walk(c)
+		}
+	}
+	walk(root)
+	return ret
+}
+
+// Trim returns cl without its pre-trip items (those due at or before the
+// trip's start), if pre, and its post-trip items (those due relative to its
+// end, e.g; "1 day before end" to order groceries), if post.
+//
+// Children are dropped with their parents. Items due relative to check-in or
+// check-out are kept, as are items whose due date cannot be parsed (they are
+// reported when expanded).
+func Trim(cl []ChecklistItem, pre, post bool) []ChecklistItem {
+	ret := []ChecklistItem{}
+	// Items indented further than dropping are children of a dropped item.
+	dropping := maxIndent + 1
+	for _, i := range cl {
+		if i.Indent > dropping {
+			continue
+		}
+		dropping = maxIndent + 1
+
+		if d, err := parseDue(i.Due); err == nil && !d.stay && ((d.end && post) || (!d.end && d.duration <= 0 && pre)) {
+			dropping = i.Indent
+			continue
+		}
+		ret = append(ret, i)
+	}
+	return ret
+}
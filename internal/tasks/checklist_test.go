@@ -1,9 +1,11 @@
 package tasks
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -17,24 +19,54 @@ func TestLoad(t *testing.T) {
 		err:  false,
 	}, {
 		csv:  "foo,1,1 day before start",
-		want: []ChecklistItem{{"foo", 1, "1 day before start"}},
+		want: []ChecklistItem{{Template: "foo", Indent: 1, Due: "1 day before start"}},
 		err:  false,
 	}, {
 		csv:  "foo,1,bizzle\nbar,1,wizzle",
-		want: []ChecklistItem{{"foo", 1, "bizzle"}, {"bar", 1, "wizzle"}},
+		want: []ChecklistItem{{Template: "foo", Indent: 1, Due: "bizzle"}, {Template: "bar", Indent: 1, Due: "wizzle"}},
 		err:  false,
 	}, {
+		// Too few fields is an error, but does not prevent loading later lines.
 		csv:  "foo\nbar,1,error",
-		want: []ChecklistItem{},
+		want: []ChecklistItem{{Template: "bar", Indent: 1, Due: "error"}},
 		err:  true,
 	}, {
 		csv:  "foo,1,e\nnot-enough-fields\nbar,3,f",
-		want: []ChecklistItem{{"foo", 1, "e"}, {"bar", 3, "f"}},
+		want: []ChecklistItem{{Template: "foo", Indent: 1, Due: "e"}, {Template: "bar", Indent: 3, Due: "f"}},
 		err:  true,
 	}, {
 		csv:  "foo,0,oof\nbar,5,rab\nbaz,1,zab", // Indent out of range.
-		want: []ChecklistItem{{"baz", 1, "zab"}},
+		want: []ChecklistItem{{Template: "baz", Indent: 1, Due: "zab"}},
+		err:  true,
+	}, {
+		csv: "foo,1,1 day before start,remind=30 minutes; remind=1 day before start\nbar,1,1 day before end",
+		want: []ChecklistItem{
+			{Template: "foo", Indent: 1, Due: "1 day before start", Reminders: []string{"30 minutes", "1 day before start"}},
+			{Template: "bar", Indent: 1, Due: "1 day before end"},
+		},
+		err: false,
+	}, {
+		csv:  "foo,1,e,remind=soon\nbar,1,f,frobnicate=yes\nbaz,1,g,remind\nqux,1,h,",
+		want: []ChecklistItem{{Template: "qux", Indent: 1, Due: "h"}},
+		err:  true,
+	}, {
+		csv: "visa,1,e,lead=30 days\npack,1,f,lead=12 hours; remind=30 minutes",
+		want: []ChecklistItem{
+			{Template: "visa", Indent: 1, Due: "e", Lead: 30 * 24 * time.Hour},
+			{Template: "pack", Indent: 1, Due: "f", Lead: 12 * time.Hour, Reminders: []string{"30 minutes"}},
+		},
+		err: false,
+	}, {
+		csv:  "foo,1,e,lead=soon\nbar,1,f,lead=0 days\nbaz,1,g,lead=1 day before",
+		want: []ChecklistItem{},
 		err:  true,
+	}, {
+		csv: "tablet,1,every day at 09:00,recur=todoist\ncall home,1,every night,recur=tasks\nreceipt,1,every day,recur=weekly",
+		want: []ChecklistItem{
+			{Template: "tablet", Indent: 1, Due: "every day at 09:00", Recur: RecurTodoist},
+			{Template: "call home", Indent: 1, Due: "every night", Recur: RecurTasks},
+		},
+		err: true,
 	}}
 
 	for _, c := range cases {
@@ -54,3 +86,95 @@ func TestLoad(t *testing.T) {
 		}
 	}
 }
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		csvs []string
+		want []string
+	}{{
+		csvs: []string{"a,1,x\nb,2,x"},
+		want: []string{"a/1", "b/2"},
+	}, {
+		// Distinct items are kept in order.
+		csvs: []string{"a,1,x", "b,1,x\nc,2,x"},
+		want: []string{"a/1", "b/1", "c/2"},
+	}, {
+		// Repeated parents gather their children; repeated items are dropped.
+		csvs: []string{
+			"Pre-trip,1,x\nVisa,2,x\nPacking,1,x\nClothes,2,x",
+			"Packing,1,y\nLaptop,2,x\nClothes,2,y\nOffice,1,x",
+		},
+		want: []string{"Pre-trip/1", "Visa/2", "Packing/1", "Clothes/2", "Laptop/2", "Office/1"},
+	}, {
+		// The same template under different parents is kept.
+		csvs: []string{"a,1,x\nc,2,x", "b,1,x\nc,2,x"},
+		want: []string{"a/1", "c/2", "b/1", "c/2"},
+	}, {
+		// Indents may skip levels.
+		csvs: []string{"a,1,x\nb,3,x\nc,2,x", "a,1,x\nd,3,x"},
+		want: []string{"a/1", "b/3", "c/2", "d/3"},
+	}}
+
+	for _, c := range cases {
+		var cls [][]ChecklistItem
+		for _, s := range c.csvs {
+			cl, err := load(strings.NewReader(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cls = append(cls, cl)
+		}
+
+		var got []string
+		for _, i := range Merge(cls...) {
+			got = append(got, fmt.Sprintf("%s/%d", i.Template, i.Indent))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Merge(%q) == %v, want %v", c.csvs, got, c.want)
+		}
+	}
+
+	// The first of a repeated item is kept.
+	got := Merge([]ChecklistItem{{Template: "a", Indent: 1, Due: "x"}}, []ChecklistItem{{Template: "a", Indent: 1, Due: "y"}})
+	if want := []ChecklistItem{{Template: "a", Indent: 1, Due: "x"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() == %v, want %v", got, want)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	cl, err := load(strings.NewReader(`Pre-trip,1,1 day before start
+Visa,2,7 days before start
+Print boarding pass,2,1 day before departure
+Packing List,1,1 day before start
+Order groceries,2,1 day before end
+Plan,1,1 day after start
+Post-trip,1,1 day after end
+Unpack,2,1 day after return
+Expenses,3,3 days after start
+Settle minibar,1,at check-out
+Broken,1,sometime`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		pre, post bool
+		want      []string
+	}{
+		{false, false, []string{"Pre-trip", "Visa", "Print boarding pass", "Packing List", "Order groceries", "Plan", "Post-trip", "Unpack", "Expenses", "Settle minibar", "Broken"}},
+		// Children go with their parents, whenever they are due.
+		{true, false, []string{"Plan", "Post-trip", "Unpack", "Expenses", "Settle minibar", "Broken"}},
+		{false, true, []string{"Pre-trip", "Visa", "Print boarding pass", "Packing List", "Plan", "Settle minibar", "Broken"}},
+		{true, true, []string{"Plan", "Settle minibar", "Broken"}},
+	}
+
+	for _, c := range cases {
+		got := []string{}
+		for _, i := range Trim(cl, c.pre, c.post) {
+			got = append(got, i.Template)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Trim(%v, %v) == %v, want %v", c.pre, c.post, got, c.want)
+		}
+	}
+}
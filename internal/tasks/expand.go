@@ -1,19 +1,54 @@
 package tasks
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// A Trip is what a checklist is expanded for.
+type Trip struct {
+	Start, End time.Time
+
+	// Destination is the timezone of items due every day of the trip. If
+	// nil, they are due in the timezone of other deadlines.
+	Destination *time.Location
+
+	// Stays are where the trip stays, in order, for items due relative to
+	// check-in or check-out.
+	Stays []Stay
+}
+
+// A Stay is somewhere a trip stays, e.g; a hotel.
+type Stay struct {
+	Name, Address, Confirmation string
+
+	// CheckIn and CheckOut are in the stay's timezone.
+	CheckIn, CheckOut time.Time
+}
+
 type due struct {
 	duration time.Duration
 
 	// end is true if the duration should be counted from the end of
-	// the trip.
+	// the trip (or stay).
 	end bool
+
+	// stay is true if the duration should be counted from check-in (or
+	// check-out, if end) of each stay rather than from the trip.
+	stay bool
+}
+
+// from returns the time this due refers to for a trip, or a stay from its
+// check-in to check-out.
+func (d due) from(start, end time.Time) time.Time {
+	if d.end {
+		return end.Add(d.duration)
+	}
+	return start.Add(d.duration)
 }
 
 func abs(t time.Duration) time.Duration {
@@ -23,129 +58,491 @@ func abs(t time.Duration) time.Duration {
 	return t
 }
 
-// Expand expands a travel checklist into a list of Tasks. If a task has a due date
-// after cutoff, it is ignored.
-func Expand(cl []ChecklistItem, start, end, now, cutoff time.Time) []Task {
+// Expand expands a travel checklist into a list of Tasks. A task is only
+// created once it is due within its item's lead time (or lead, if the item
+// has none) of now, and until it is past due; a recurring task, until its
+// last occurrence is. Parents are created whenever any of their children are.
+// Deadlines are set in now's Location(), except for items due every day (or
+// night) of the trip (see ExpandAll).
+func Expand(ctx context.Context, cl []ChecklistItem, t Trip, now time.Time, lead time.Duration) []Task {
+	all, spans := expand(ctx, cl, t, now.Location())
+
+	include := make([]bool, len(all))
+	for n, t := range all {
+		l := lead
+		if i := cl[t.Position]; i.Lead > 0 {
+			l = i.Lead
+		}
+
+		// Tasks are created once within their lead time, until they are
+		// past due; there's no point creating them in vain.
+		include[n] = !spans[n].last.Before(now) && spans[n].first.Before(now.Add(l))
+	}
+	includeParents(all, include)
+
 	ret := []Task{}
-	tasksWithinCutoff := false
+	for n, t := range all {
+		if include[n] {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// ExpandAll expands every item of a travel checklist into Tasks, however far
+// off or long past they are due. Deadlines are set in loc. Items due every
+// day (or night) of the trip are due at their time of day at t's
+// Destination, or in loc if it has none, and expand to a task for each day
+// unless they recur in Todoist. Items due relative to check-in or check-out
+// expand to a task for each of t's Stays.
+func ExpandAll(ctx context.Context, cl []ChecklistItem, t Trip, loc *time.Location) []Task {
+	ret, _ := expand(ctx, cl, t, loc)
+	return ret
+}
 
+// A span is when a task is first and last due; they differ only for
+// recurring tasks.
+type span struct {
+	first, last time.Time
+}
+
+func expand(ctx context.Context, cl []ChecklistItem, t Trip, loc *time.Location) ([]Task, []span) {
+	dest := t.Destination
+	if dest == nil {
+		dest = loc
+	}
+
+	ret := []Task{}
+	var spans []span
 	for pos, i := range cl {
-		var dd time.Time
-		d, err := parseDue(i.Due)
-		if err != nil {
-			log.Printf("Could not process due for task %q: %v (ignored)", i.Template, err)
+		if isRecurrence(i.Due) {
+			ts, ss := expandRecurrence(ctx, i, pos, t, dest)
+			ret = append(ret, ts...)
+			spans = append(spans, ss...)
 			continue
 		}
-		if d.end {
-			dd = end.Add(d.duration)
-		} else {
-			dd = start.Add(d.duration)
-		}
 
-		// If a due date is greater/equal than 1 day away from a reference point, then
-		// adjust the deadline to be near the end of that day.
-		//
-		// Use the cutoff's Location() as the timezone for the task deadline.
-		if abs(d.duration) >= 24*time.Hour {
-			dd = time.Date(dd.Year(), dd.Month(), dd.Day(), 20, 00, 00, 00, cutoff.Location())
+		d, err := parseDue(i.Due)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not process due date, ignoring task", "task", i.Template, "error", err)
+			continue
 		}
 
-		// If the due date has already passed, don't create in vain.
-		if dd.Before(now) {
+		if !d.stay {
+			task := expandDue(i, pos, d, t.Start, t.End, loc)
+			task.Content = expandTemplate(i.Template, t.Start, t.End)
+			task.Reminders = expandReminders(ctx, i, t, nil)
+			ret = append(ret, task)
+			spans = append(spans, span{task.DueDateUTC, task.DueDateUTC})
 			continue
 		}
 
-		if dd.Before(cutoff) {
-			tasksWithinCutoff = true
+		for n := range t.Stays {
+			s := &t.Stays[n]
+			task := expandDue(i, pos, d, s.CheckIn, s.CheckOut, loc)
+			task.Content = expandStay(expandTemplate(i.Template, t.Start, t.End), s)
+			task.Reminders = expandReminders(ctx, i, t, s)
+			ret = append(ret, task)
+			spans = append(spans, span{task.DueDateUTC, task.DueDateUTC})
 		}
+	}
+	return ret, spans
+}
+
+// expandDue returns the task, without content or reminders, for the item at
+// position pos of its checklist, due d relative to start and end.
+func expandDue(i ChecklistItem, pos int, d due, start, end time.Time, loc *time.Location) Task {
+	dd := d.from(start, end)
+
+	// If a due date is greater/equal than 1 day away from a reference point, then
+	// adjust the deadline to be near the end of that day, in loc.
+	if abs(d.duration) >= 24*time.Hour {
+		dd = time.Date(dd.Year(), dd.Month(), dd.Day(), 20, 00, 00, 00, loc)
+	}
+
+	return Task{
+		Indent:     i.Indent,
+		DueDateUTC: dd.UTC(),
+		Position:   pos,
+	}
+}
+
+// expandStay expands the keywords HOTEL, ADDRESS, CONFIRMATION and DATE (its
+// check-in date) in c for s. The stay's name is added to the end of c if it
+// has no HOTEL, and its check-in date if it has no DATE, so each stay's task
+// is distinct and keeps its name however the stays change.
+func expandStay(c string, s *Stay) string {
+	date := s.CheckIn.Format(DateFormat)
+	ret := strings.NewReplacer("HOTEL", s.Name, "ADDRESS", s.Address, "CONFIRMATION", s.Confirmation, "DATE", date).Replace(c)
+
+	var suffix []string
+	if !strings.Contains(c, "HOTEL") {
+		suffix = append(suffix, s.Name)
+	}
+	if !strings.Contains(c, "DATE") {
+		suffix = append(suffix, date)
+	}
+	if len(suffix) > 0 {
+		ret = fmt.Sprintf("%s (%s)", ret, strings.Join(suffix, ", "))
+	}
+	return ret
+}
+
+// todoistZone returns the name of loc for i's recurrence in Todoist, which
+// only knows IANA zones. time.Local has none, so UTC stands in for it.
+func todoistZone(ctx context.Context, i ChecklistItem, loc *time.Location) string {
+	if loc.String() == "Local" {
+		slog.WarnContext(ctx, "No named time zone for the trip, recurring in UTC; set HomeTimezone", "task", i.Template)
+		return time.UTC.String()
+	}
+	return loc.String()
+}
+
+// expandRecurrence expands an item due every day (or night) of the trip,
+// at position pos of its checklist, into a task for each day, or one
+// recurring task.
+func expandRecurrence(ctx context.Context, i ChecklistItem, pos int, t Trip, dest *time.Location) ([]Task, []span) {
+	r, err := parseRecurrence(i.Due)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not process due date, ignoring task", "task", i.Template, "error", err)
+		return nil, nil
+	}
+	days := r.days(t.Start, t.End, dest)
+	if len(days) == 0 {
+		return nil, nil
+	}
+	content := expandTemplate(i.Template, t.Start, t.End)
 
-		ret = append(ret, Task{
-			Content:    expandTemplate(i.Template, start, end),
+	if i.Recur == RecurTodoist {
+		first, last := days[0], days[len(days)-1]
+		return []Task{{
+			// One task has no date to stand in for DATE.
+			Content:        strings.Join(strings.Fields(strings.Replace(content, "DATE", "", -1)), " "),
+			Indent:         i.Indent,
+			Position:       pos,
+			Recurrence:     r.todoist(first, last),
+			RecurrenceZone: todoistZone(ctx, i, dest),
+			Reminders:      expandReminders(ctx, i, t, nil),
+		}}, []span{{first, last}}
+	}
+
+	var ts []Task
+	var ss []span
+	for _, d := range days {
+		date := d.Format(DateFormat)
+		c := strings.Replace(content, "DATE", date, -1)
+		if c == content {
+			c = fmt.Sprintf("%s (%s)", content, date)
+		}
+		ts = append(ts, Task{
+			Content:    c,
 			Indent:     i.Indent,
-			DueDateUTC: dd.UTC(),
+			DueDateUTC: d.UTC(),
 			Position:   pos,
+			Reminders:  expandReminders(ctx, i, t, nil),
 		})
+		ss = append(ss, span{d, d})
 	}
+	return ts, ss
+}
+
+// includeParents marks the parents of included tasks as included too. A
+// task's children are the tasks after it that are indented further.
+func includeParents(ts []Task, include []bool) {
+	// below[n] is set if an included task at indent n follows, and has not
+	// yet been claimed by a parent.
+	var below [maxIndent + 2]bool
+	for n := len(ts) - 1; n >= 0; n-- {
+		indent := ts[n].Indent
+		for c := indent + 1; c < len(below); c++ {
+			if below[c] {
+				include[n] = true
+			}
+			below[c] = false
+		}
+		below[indent] = below[indent] || include[n]
+	}
+}
+
+// expandReminders expands the reminders for a checklist item, for stay s if
+// the item is due relative to one. Reminders that cannot be parsed, or are
+// relative to check-in or check-out of an item that is not, are logged and
+// ignored.
+func expandReminders(ctx context.Context, i ChecklistItem, t Trip, s *Stay) []Reminder {
+	var ret []Reminder
+	for _, rs := range i.Reminders {
+		r, err := parseReminder(rs)
+		if err == nil && r.at != nil && r.at.stay && s == nil {
+			err = fmt.Errorf("only items due relative to check-in or check-out may have reminders relative to them")
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Could not process reminder, ignoring it", "task", i.Template, "reminder", rs, "error", err)
+			continue
+		}
 
-	if tasksWithinCutoff {
-		return ret
-	} else {
-		return []Task{}
+		switch {
+		case r.at == nil:
+			ret = append(ret, Reminder{MinutesBefore: int(r.before / time.Minute)})
+		case r.at.stay:
+			ret = append(ret, Reminder{TimeUTC: r.at.from(s.CheckIn, s.CheckOut).UTC()})
+		default:
+			ret = append(ret, Reminder{TimeUTC: r.at.from(t.Start, t.End).UTC()})
+		}
 	}
+	SortReminders(ret)
+	return ret
 }
 
 // parseDue expands a humanized due string into a due structure. A due
-// string looks like: "16 hours before start" or "1 day after end"
+// string looks like: "16 hours before start", "1 day after end", "2 hours
+// before check-in" or "at check-out".
 func parseDue(s string) (due, error) {
 	var ret due
 	parts := strings.SplitN(strings.ToLower(s), " ", 4)
+	if len(parts) == 2 && parts[0] == "at" {
+		parts = []string{"0", "minutes", "after", parts[1]}
+	}
 	if len(parts) < 4 {
 		return ret, fmt.Errorf("due date not fully specified %q", s)
 	}
 
-	t, err := strconv.Atoi(parts[0])
+	var err error
+	ret.duration, err = parseDuration(parts[0], parts[1])
 	if err != nil {
 		return ret, err
 	}
 
-	switch d := parts[1]; d {
+	switch parts[2] {
+	case "after":
+		// Nothing.
+
+	case "from":
+		fallthrough
+	case "before":
+		ret.duration = -ret.duration
+
+	default:
+		return ret, fmt.Errorf("unknown relation %q", parts[2])
+	}
+
+	switch parts[3] {
+	case "departure":
+		fallthrough
+	case "start":
+		// Nothing.
+
+	case "return":
+		fallthrough
+	case "end":
+		ret.end = true
+
+	case "check-in", "checkin":
+		ret.stay = true
+
+	case "check-out", "checkout":
+		ret.stay = true
+		ret.end = true
+
+	default:
+		return ret, fmt.Errorf("unknown reference %q", parts[3])
+	}
+
+	return ret, nil
+}
+
+// A recurrence is due every day, or night, of a trip at a time of day. A
+// recurrence string looks like: "every day at 09:00" or "every night"; days
+// are due at 20:00 unless they say otherwise.
+type recurrence struct {
+	// nightly recurrences skip the day the trip ends.
+	nightly bool
+
+	hour, minute int
+}
+
+// isRecurrence returns whether s is a recurrence string rather than a due
+// string.
+func isRecurrence(s string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), "every ")
+}
+
+// parseRecurrence expands a humanised recurrence string into a recurrence.
+func parseRecurrence(s string) (recurrence, error) {
+	ret := recurrence{hour: 20}
+	parts := strings.Fields(strings.ToLower(s))
+	if len(parts) != 2 && len(parts) != 4 || parts[0] != "every" {
+		return ret, fmt.Errorf("recurrence not in \"every <day|night> [at HH:MM]\" form %q", s)
+	}
+
+	switch parts[1] {
+	case "day":
+		// Nothing.
+
+	case "night":
+		ret.nightly = true
+
+	default:
+		return ret, fmt.Errorf("unknown recurrence %q (want day or night)", parts[1])
+	}
+
+	if len(parts) == 4 {
+		if parts[2] != "at" {
+			return ret, fmt.Errorf("unknown relation %q in recurrence (only at is supported)", parts[2])
+		}
+		t, err := time.Parse("15:04", parts[3])
+		if err != nil {
+			return ret, fmt.Errorf("time of day %q not in HH:MM form", parts[3])
+		}
+		ret.hour, ret.minute = t.Hour(), t.Minute()
+	}
+	return ret, nil
+}
+
+// days returns when r is due, in loc, on each calendar day of the trip from
+// start to end (or each night: every day but the last).
+func (r recurrence) days(start, end time.Time, loc *time.Location) []time.Time {
+	s, e := start.In(loc), end.In(loc)
+	last := time.Date(e.Year(), e.Month(), e.Day(), r.hour, r.minute, 00, 00, loc)
+	if r.nightly {
+		last = last.AddDate(0, 0, -1)
+	}
+
+	var ret []time.Time
+	for d := time.Date(s.Year(), s.Month(), s.Day(), r.hour, r.minute, 00, 00, loc); !d.After(last); d = d.AddDate(0, 0, 1) {
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// todoist returns r as a Todoist recurring due string, from first to last.
+func (r recurrence) todoist(first, last time.Time) string {
+	return fmt.Sprintf("every day at %02d:%02d starting %s until %s", r.hour, r.minute,
+		first.Format("2006-01-02"), last.Format("2006-01-02"))
+}
+
+// NormalizeRecurrence rewrites a Todoist recurring due string of the form
+// written by Expand, so that one read back from Todoist compares equal to
+// the one written. Todoist may change its case and spacing, or write times
+// as e.g; "9am". Strings in any other form are returned unchanged.
+func NormalizeRecurrence(s string) string {
+	parts := strings.Fields(strings.ToLower(s))
+	if len(parts) != 8 || parts[0] != "every" || parts[1] != "day" || parts[2] != "at" {
+		return s
+	}
+	if parts[4] != "starting" && parts[4] != "from" || parts[6] != "until" && parts[6] != "ending" {
+		return s
+	}
+
+	var tod time.Time
+	var err error
+	for _, layout := range []string{"15:04", "3pm", "3:04pm"} {
+		if tod, err = time.Parse(layout, parts[3]); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return s
+	}
+	first, err := time.Parse("2006-01-02", parts[5])
+	if err != nil {
+		return s
+	}
+	last, err := time.Parse("2006-01-02", parts[7])
+	if err != nil {
+		return s
+	}
+
+	r := recurrence{hour: tod.Hour(), minute: tod.Minute()}
+	return r.todoist(first, last)
+}
+
+// parseDuration parses a humanised duration such as "3 hours" given as its
+// count and unit.
+func parseDuration(count, unit string) (time.Duration, error) {
+	t, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, err
+	}
+
+	switch unit {
 	case "minute":
 		fallthrough
 	case "minutes":
-		ret.duration = time.Duration(t) * time.Minute
+		return time.Duration(t) * time.Minute, nil
 
 	case "hour":
 		fallthrough
 	case "hours":
-		ret.duration = time.Duration(t) * time.Hour
+		return time.Duration(t) * time.Hour, nil
 
 	case "day":
 		fallthrough
 	case "days":
-		ret.duration = time.Duration(t*24) * time.Hour
+		return time.Duration(t*24) * time.Hour, nil
 
 	case "week":
 		fallthrough
 	case "weeks":
-		ret.duration = time.Duration(t*24*7) * time.Hour
+		return time.Duration(t*24*7) * time.Hour, nil
 
 	default:
 		// Just try it.
-		ret.duration, err = time.ParseDuration(d)
+		d, err := time.ParseDuration(unit)
 		if err != nil {
-			return ret, fmt.Errorf("unknown unit %q", d)
+			return 0, fmt.Errorf("unknown unit %q", unit)
 		}
+		return d, nil
 	}
+}
 
-	switch parts[2] {
-	case "after":
-		// Nothing.
+type reminder struct {
+	// before is how long before the task is due a relative reminder fires.
+	before time.Duration
 
-	case "from":
-		fallthrough
-	case "before":
-		ret.duration = -ret.duration
+	// at is set for absolute reminders, which fire relative to the trip
+	// rather than to the task.
+	at *due
+}
 
-	default:
-		return ret, fmt.Errorf("unknown relation %q", parts[2])
+// parseReminder expands a humanised reminder string into a reminder. A
+// reminder string is either relative to the task ("30 minutes before" or just
+// "30 minutes") or a full due string relative to the trip ("1 day before
+// start" or "at check-in").
+func parseReminder(s string) (reminder, error) {
+	var ret reminder
+	parts := strings.Fields(strings.ToLower(s))
+	if len(parts) == 2 && parts[0] == "at" {
+		parts = []string{"0", "minutes", "after", parts[1]}
 	}
 
-	switch parts[3] {
-	case "departure":
-		fallthrough
-	case "start":
-		// Nothing.
+	switch len(parts) {
+	case 4:
+		d, err := parseDue(strings.Join(parts, " "))
+		if err != nil {
+			return ret, err
+		}
+		ret.at = &d
+		return ret, nil
 
-	case "return":
+	case 3:
+		if parts[2] != "before" {
+			return ret, fmt.Errorf("unknown relation %q in reminder (only before is supported)", parts[2])
+		}
 		fallthrough
-	case "end":
-		ret.end = true
 
-	default:
-		return ret, fmt.Errorf("unknown reference %q", parts[3])
+	case 2:
+		d, err := parseDuration(parts[0], parts[1])
+		if err != nil {
+			return ret, err
+		}
+		if d < 0 || d%time.Minute != 0 {
+			return ret, fmt.Errorf("reminder %q must be a whole, positive number of minutes", s)
+		}
+		ret.before = d
+		return ret, nil
 	}
 
-	return ret, nil
+	return ret, fmt.Errorf("reminder not fully specified %q", s)
 }
 
 // expandTemplate expands a template for a given trip. Right now, this just
@@ -1,6 +1,7 @@
 package tasks
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
@@ -10,19 +11,20 @@ func TestExpand(t *testing.T) {
 	tripStart := time.Date(2016, 07, 15, 00, 00, 00, 00, time.UTC)
 	tripEnd := time.Date(2016, 07, 20, 12, 30, 00, 00, time.UTC)
 	now := time.Date(2016, 07, 01, 10, 00, 00, 00, time.UTC)
-	stdCutoff := time.Date(2016, 07, 10, 00, 00, 00, 00, time.UTC)
+	stdLead := time.Date(2016, 07, 10, 00, 00, 00, 00, time.UTC).Sub(now)
+	longLead := tripEnd.Sub(now)
 
 	cases := []struct {
-		in     []ChecklistItem
-		cutoff time.Time
-		want   []Task
+		in   []ChecklistItem
+		lead time.Duration
+		want []Task
 	}{{
 		in:   []ChecklistItem{},
 		want: []Task{},
 	}, {
 		// Test due date.
-		in:     []ChecklistItem{{Template: "foo", Indent: 1, Due: "14 days before start"}},
-		cutoff: stdCutoff,
+		in:   []ChecklistItem{{Template: "foo", Indent: 1, Due: "14 days before start"}},
+		lead: stdLead,
 		want: []Task{{
 			Content:    "foo",
 			Indent:     1,
@@ -30,8 +32,8 @@ func TestExpand(t *testing.T) {
 		}},
 	}, {
 		// Test template expansion.
-		in:     []ChecklistItem{{Template: "trip has DAYS", Indent: 1, Due: "8 days before start"}},
-		cutoff: stdCutoff,
+		in:   []ChecklistItem{{Template: "trip has DAYS", Indent: 1, Due: "8 days before start"}},
+		lead: stdLead,
 		want: []Task{{
 			Content:    "trip has 5 days",
 			Indent:     1,
@@ -43,15 +45,15 @@ func TestExpand(t *testing.T) {
 			{Template: "DAYS", Indent: 1, Due: "1 day before start"},
 			{Template: "foo bar", Indent: 1, Due: "1 day before end"},
 		},
-		cutoff: tripEnd,
+		lead: longLead,
 		want: []Task{
 			{Content: "5 days", Indent: 1, DueDateUTC: time.Date(2016, 07, 14, 20, 00, 00, 00, time.UTC)},
 			{Content: "foo bar", Indent: 1, DueDateUTC: time.Date(2016, 07, 19, 20, 00, 00, 00, time.UTC), Position: 1},
 		},
 	}, {
 		// Tasks <24h from a boundary should not be adjusted to 20:00hrs.
-		in:     []ChecklistItem{{Template: "no time adjust", Indent: 1, Due: "3 hours before start"}},
-		cutoff: tripEnd,
+		in:   []ChecklistItem{{Template: "no time adjust", Indent: 1, Due: "3 hours before start"}},
+		lead: longLead,
 		want: []Task{{
 			Content:    "no time adjust",
 			Indent:     1,
@@ -62,38 +64,276 @@ func TestExpand(t *testing.T) {
 		in:   []ChecklistItem{{Template: "due date already passed", Indent: 1, Due: "15 days before start"}},
 		want: []Task{},
 	}, {
-		// Tasks after the cutoff should be excluded.
+		// Tasks beyond their lead time are excluded, whatever else is due.
 		in: []ChecklistItem{
-			{Template: "before cutoff", Indent: 1, Due: "8 days before start"},
-			{Template: "after cutoff but included because above", Indent: 1, Due: "4 days before start"},
+			{Template: "within lead", Indent: 1, Due: "8 days before start"},
+			{Template: "beyond lead", Indent: 1, Due: "4 days before start"},
 		},
-		cutoff: stdCutoff,
+		lead: stdLead,
 		want: []Task{{
-			Content:    "before cutoff",
+			Content:    "within lead",
 			Indent:     1,
 			DueDateUTC: time.Date(2016, 07, 07, 20, 00, 00, 00, time.UTC),
-		}, {
-			Content:    "after cutoff but included because above",
+		}},
+	}, {
+		// Tasks beyond their lead time should be excluded.
+		in: []ChecklistItem{
+			{Template: "beyond lead", Indent: 1, Due: "4 days before start"},
+		},
+		lead: stdLead,
+		want: []Task{},
+	}, {
+		// Items may have their own lead time, longer or shorter.
+		in: []ChecklistItem{
+			{Template: "visa", Indent: 1, Due: "4 days before start", Lead: 30 * 24 * time.Hour},
+			{Template: "pack", Indent: 1, Due: "8 days before start", Lead: 2 * 24 * time.Hour},
+		},
+		lead: stdLead,
+		want: []Task{{
+			Content:    "visa",
 			Indent:     1,
-			Position:   1,
 			DueDateUTC: time.Date(2016, 07, 11, 20, 00, 00, 00, time.UTC),
 		}},
 	}, {
-		// Tasks after the cutoff should be excluded.
+		// Parents are included when any child is, even if they are not
+		// themselves due yet (or are past due).
 		in: []ChecklistItem{
-			{Template: "after cutoff", Indent: 1, Due: "4 days before start"},
+			{Template: "pre-trip", Indent: 1, Due: "1 day before start"},
+			{Template: "visa", Indent: 2, Due: "8 days before start"},
+			{Template: "charge", Indent: 3, Due: "12 days before start"},
+			{Template: "pack", Indent: 2, Due: "1 day before start"},
+			{Template: "post-trip", Indent: 1, Due: "16 days before start"},
+			{Template: "unpack", Indent: 2, Due: "1 day after end"},
+			{Template: "groceries", Indent: 2, Due: "10 days before start"},
+		},
+		lead: stdLead,
+		want: []Task{
+			{Content: "pre-trip", Indent: 1, DueDateUTC: time.Date(2016, 07, 14, 20, 00, 00, 00, time.UTC)},
+			{Content: "visa", Indent: 2, Position: 1, DueDateUTC: time.Date(2016, 07, 07, 20, 00, 00, 00, time.UTC)},
+			{Content: "charge", Indent: 3, Position: 2, DueDateUTC: time.Date(2016, 07, 03, 20, 00, 00, 00, time.UTC)},
+			{Content: "post-trip", Indent: 1, Position: 4, DueDateUTC: time.Date(2016, 06, 29, 20, 00, 00, 00, time.UTC)},
+			{Content: "groceries", Indent: 2, Position: 6, DueDateUTC: time.Date(2016, 07, 05, 20, 00, 00, 00, time.UTC)},
 		},
-		cutoff: stdCutoff,
-		want:   []Task{},
+	}, {
+		// Reminders are expanded and sorted.
+		in: []ChecklistItem{{
+			Template:  "hail taxi",
+			Indent:    1,
+			Due:       "3 hours before start",
+			Reminders: []string{"1 day before start", "30 minutes", "2 hours before"},
+		}},
+		lead: longLead,
+		want: []Task{{
+			Content:    "hail taxi",
+			Indent:     1,
+			DueDateUTC: time.Date(2016, 07, 14, 21, 00, 00, 00, time.UTC),
+			Reminders: []Reminder{
+				{MinutesBefore: 120},
+				{MinutesBefore: 30},
+				{TimeUTC: time.Date(2016, 07, 14, 00, 00, 00, 00, time.UTC)},
+			},
+		}},
 	}}
 	for _, c := range cases {
-		got := Expand(c.in, tripStart, tripEnd, now, c.cutoff)
+		got := Expand(context.Background(), c.in, Trip{Start: tripStart, End: tripEnd}, now, c.lead)
 		if !reflect.DeepEqual(got, c.want) {
 			t.Errorf("Expand(%v) == %v, want %v", c.in, got, c.want)
 		}
 	}
 }
 
+func TestExpandAll(t *testing.T) {
+	tripStart := time.Date(2016, 07, 15, 00, 00, 00, 00, time.UTC)
+	tripEnd := time.Date(2016, 07, 20, 12, 30, 00, 00, time.UTC)
+	cl := []ChecklistItem{
+		{Template: "visa", Indent: 1, Due: "10 weeks before start"},
+		{Template: "broken", Indent: 1, Due: "whenever"},
+		{Template: "unpack", Indent: 1, Due: "1 day after end"},
+	}
+
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		t.Fatalf("Could not load timezone: %v", err)
+	}
+	want := []Task{
+		{Content: "visa", Indent: 1, DueDateUTC: time.Date(2016, 05, 06, 19, 00, 00, 00, time.UTC)},
+		{Content: "unpack", Indent: 1, Position: 2, DueDateUTC: time.Date(2016, 07, 21, 19, 00, 00, 00, time.UTC)},
+	}
+	if got := ExpandAll(context.Background(), cl, Trip{Start: tripStart, End: tripEnd}, dublin); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandAll(%v) == %v, want %v", cl, got, want)
+	}
+}
+
+func TestExpandRecurrence(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatalf("Could not load timezone: %v", err)
+	}
+	// The trip lands in Lisbon at 23:30 on the 14th, local time, and leaves
+	// early on the 17th.
+	tripStart := time.Date(2016, 07, 14, 22, 30, 00, 00, time.UTC)
+	tripEnd := time.Date(2016, 07, 17, 06, 00, 00, 00, time.UTC)
+	trip := Trip{Start: tripStart, End: tripEnd, Destination: lisbon}
+	at := func(day, hour int) time.Time {
+		return time.Date(2016, 07, day, hour, 00, 00, 00, lisbon).UTC()
+	}
+
+	cl := []ChecklistItem{
+		{Template: "Take tablet", Indent: 1, Due: "every day at 09:00"},
+		{Template: "Call home on DATE", Indent: 1, Due: "every night", Reminders: []string{"30 minutes"}},
+		{Template: "Submit receipts", Indent: 1, Due: "every night at 21:00", Recur: RecurTodoist},
+		{Template: "broken", Indent: 1, Due: "every week"},
+	}
+	want := []Task{
+		{Content: "Take tablet (Thu 14 Jul)", Indent: 1, DueDateUTC: at(14, 9)},
+		{Content: "Take tablet (Fri 15 Jul)", Indent: 1, DueDateUTC: at(15, 9)},
+		{Content: "Take tablet (Sat 16 Jul)", Indent: 1, DueDateUTC: at(16, 9)},
+		{Content: "Take tablet (Sun 17 Jul)", Indent: 1, DueDateUTC: at(17, 9)},
+		{Content: "Call home on Thu 14 Jul", Indent: 1, Position: 1, DueDateUTC: at(14, 20), Reminders: []Reminder{{MinutesBefore: 30}}},
+		{Content: "Call home on Fri 15 Jul", Indent: 1, Position: 1, DueDateUTC: at(15, 20), Reminders: []Reminder{{MinutesBefore: 30}}},
+		{Content: "Call home on Sat 16 Jul", Indent: 1, Position: 1, DueDateUTC: at(16, 20), Reminders: []Reminder{{MinutesBefore: 30}}},
+		{Content: "Submit receipts", Indent: 1, Position: 2,
+			Recurrence: "every day at 21:00 starting 2016-07-14 until 2016-07-16", RecurrenceZone: "Europe/Lisbon"},
+	}
+	if got := ExpandAll(context.Background(), cl, trip, time.UTC); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandAll(%v) == %v, want %v", cl, got, want)
+	}
+
+	// Without a named zone, Todoist recurs in UTC, and tasks without a date
+	// drop DATE.
+	local := Trip{Start: tripStart, End: tripEnd}
+	hotel := []ChecklistItem{{Template: "Check in DATE at hotel", Indent: 1, Due: "every day at 09:00", Recur: RecurTodoist}}
+	if got := ExpandAll(context.Background(), hotel, local, time.Local); len(got) != 1 || got[0].Content != "Check in at hotel" || got[0].RecurrenceZone != "UTC" {
+		t.Errorf("ExpandAll(%v, Local) == %v, want %q in UTC", hotel, got, "Check in at hotel")
+	}
+
+	// Recurring tasks are created within their lead time of the first day,
+	// until the last.
+	cases := []struct {
+		now  time.Time
+		want []string
+	}{
+		{at(12, 12), nil},
+		{at(13, 22), []string{"Take tablet (Thu 14 Jul)", "Call home on Thu 14 Jul", "Submit receipts"}},
+		{at(16, 12), []string{"Take tablet (Sun 17 Jul)", "Call home on Sat 16 Jul", "Submit receipts"}},
+		{at(16, 22), []string{"Take tablet (Sun 17 Jul)"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, t := range Expand(context.Background(), cl[:3], trip, c.now, 24*time.Hour) {
+			got = append(got, t.Content)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Expand(now=%v) == %v, want %v", c.now, got, c.want)
+		}
+	}
+}
+
+func TestExpandStays(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatalf("Could not load timezone: %v", err)
+	}
+	at := func(day, hour int) time.Time {
+		return time.Date(2016, 07, day, hour, 00, 00, 00, lisbon)
+	}
+	trip := Trip{
+		Start: at(15, 9),
+		End:   at(22, 18),
+		Stays: []Stay{
+			{Name: "Hotel Lisboa", Address: "1 Rua Augusta", Confirmation: "L1", CheckIn: at(15, 15), CheckOut: at(18, 11)},
+			{Name: "Hotel Porto", Confirmation: "P2", CheckIn: at(18, 16), CheckOut: at(20, 11)},
+			{Name: "Hotel Lisboa", Confirmation: "L3", CheckIn: at(20, 15), CheckOut: at(22, 11)},
+		},
+	}
+
+	cl := []ChecklistItem{
+		{Template: "Confirm late check-in at HOTEL (CONFIRMATION)", Indent: 1, Due: "1 day before check-in"},
+		{Template: "Check in on DATE", Indent: 1, Due: "at check-in"},
+		{Template: "Settle minibar", Indent: 1, Due: "at check-out", Reminders: []string{"at check-out", "1 hour before check-in", "1 day before start"}},
+		{Template: "Unpack", Indent: 1, Due: "1 day after end", Reminders: []string{"at check-in"}},
+	}
+	want := []Task{
+		{Content: "Confirm late check-in at Hotel Lisboa (L1) (Fri 15 Jul)", Indent: 1, DueDateUTC: time.Date(2016, 07, 14, 20, 00, 00, 00, time.UTC)},
+		{Content: "Confirm late check-in at Hotel Porto (P2) (Mon 18 Jul)", Indent: 1, DueDateUTC: time.Date(2016, 07, 17, 20, 00, 00, 00, time.UTC)},
+		{Content: "Confirm late check-in at Hotel Lisboa (L3) (Wed 20 Jul)", Indent: 1, DueDateUTC: time.Date(2016, 07, 19, 20, 00, 00, 00, time.UTC)},
+		{Content: "Check in on Fri 15 Jul (Hotel Lisboa)", Indent: 1, Position: 1, DueDateUTC: at(15, 15).UTC()},
+		{Content: "Check in on Mon 18 Jul (Hotel Porto)", Indent: 1, Position: 1, DueDateUTC: at(18, 16).UTC()},
+		{Content: "Check in on Wed 20 Jul (Hotel Lisboa)", Indent: 1, Position: 1, DueDateUTC: at(20, 15).UTC()},
+		{Content: "Settle minibar (Hotel Lisboa, Fri 15 Jul)", Indent: 1, Position: 2, DueDateUTC: at(18, 11).UTC(), Reminders: []Reminder{
+			{TimeUTC: at(14, 9).UTC()}, {TimeUTC: at(15, 14).UTC()}, {TimeUTC: at(18, 11).UTC()},
+		}},
+		{Content: "Settle minibar (Hotel Porto, Mon 18 Jul)", Indent: 1, Position: 2, DueDateUTC: at(20, 11).UTC(), Reminders: []Reminder{
+			{TimeUTC: at(14, 9).UTC()}, {TimeUTC: at(18, 15).UTC()}, {TimeUTC: at(20, 11).UTC()},
+		}},
+		{Content: "Settle minibar (Hotel Lisboa, Wed 20 Jul)", Indent: 1, Position: 2, DueDateUTC: at(22, 11).UTC(), Reminders: []Reminder{
+			{TimeUTC: at(14, 9).UTC()}, {TimeUTC: at(20, 14).UTC()}, {TimeUTC: at(22, 11).UTC()},
+		}},
+		{Content: "Unpack", Indent: 1, Position: 3, DueDateUTC: time.Date(2016, 07, 23, 20, 00, 00, 00, time.UTC)},
+	}
+	if got := ExpandAll(context.Background(), cl, trip, time.UTC); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandAll(%v) == %v, want %v", cl, got, want)
+	}
+
+	// Trips without stays have no tasks for items due relative to them.
+	trip.Stays = nil
+	if got := ExpandAll(context.Background(), cl[:3], trip, time.UTC); len(got) != 0 {
+		t.Errorf("ExpandAll(%v) without stays == %v, want none", cl[:3], got)
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	cases := []struct {
+		in        string
+		want      recurrence
+		wantError bool
+	}{
+		{in: "every day", want: recurrence{hour: 20}},
+		{in: "Every Night at 21:30", want: recurrence{nightly: true, hour: 21, minute: 30}},
+		{in: "every day at 9:00", want: recurrence{hour: 9}},
+		{in: "every week", wantError: true},
+		{in: "every day by 09:00", wantError: true},
+		{in: "every day at noon", wantError: true},
+		{in: "every day at", wantError: true},
+	}
+
+	for _, c := range cases {
+		got, err := parseRecurrence(c.in)
+		if (err != nil) != c.wantError {
+			t.Errorf("parseRecurrence(%q) error %v, want error %v", c.in, err, c.wantError)
+			continue
+		}
+		if err == nil && got != c.want {
+			t.Errorf("parseRecurrence(%q) == %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeRecurrence(t *testing.T) {
+	const want = "every day at 09:00 starting 2016-07-14 until 2016-07-20"
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{want, want},
+		{"Every Day at 09:00 starting 2016-07-14 until 2016-07-20", want},
+		{"every day  at 9:00 starting 2016-07-14 until 2016-07-20", want},
+		{"every day at 9am starting 2016-07-14 until 2016-07-20", want},
+		{"every day at 9:00am from 2016-07-14 ending 2016-07-20", want},
+		{"every day at 9:30pm starting 2016-07-14 until 2016-07-20", "every day at 21:30 starting 2016-07-14 until 2016-07-20"},
+		{"every day at noon starting 2016-07-14 until 2016-07-20", "every day at noon starting 2016-07-14 until 2016-07-20"},
+		{"every week at 09:00 starting 2016-07-14 until 2016-07-20", "every week at 09:00 starting 2016-07-14 until 2016-07-20"},
+		{"every day at 09:00 starting Jul 14 until Jul 20", "every day at 09:00 starting Jul 14 until Jul 20"},
+		{"every day", "every day"},
+	}
+
+	for _, c := range cases {
+		if got := NormalizeRecurrence(c.in); got != c.want {
+			t.Errorf("NormalizeRecurrence(%q) == %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
 func TestParseDue(t *testing.T) {
 	cases := []struct {
 		in        string
@@ -111,6 +351,18 @@ func TestParseDue(t *testing.T) {
 	}, {
 		in:   "2 weeks before start",
 		want: due{duration: -2 * 7 * 24 * time.Hour},
+	}, {
+		in:   "2 hours before check-in",
+		want: due{duration: -2 * time.Hour, stay: true},
+	}, {
+		in:   "at checkout",
+		want: due{end: true, stay: true},
+	}, {
+		in:   "at start",
+		want: due{},
+	}, {
+		in:        "at noon",
+		wantError: true,
 	}, {
 		in:        "",
 		wantError: true,
@@ -143,6 +395,55 @@ func TestParseDue(t *testing.T) {
 
 }
 
+func TestParseReminder(t *testing.T) {
+	cases := []struct {
+		in        string
+		want      reminder
+		wantError bool
+	}{{
+		in:   "30 minutes",
+		want: reminder{before: 30 * time.Minute},
+	}, {
+		in:   "2 hours before",
+		want: reminder{before: 2 * time.Hour},
+	}, {
+		in:   "1 day before start",
+		want: reminder{at: &due{duration: -24 * time.Hour}},
+	}, {
+		in:   "at check-in",
+		want: reminder{at: &due{stay: true}},
+	}, {
+		in:        "2 hours after",
+		wantError: true,
+	}, {
+		in:        "30s",
+		wantError: true,
+	}, {
+		in:        "1 30s",
+		wantError: true,
+	}, {
+		in:        "1 day before commencement",
+		wantError: true,
+	}}
+
+	for _, c := range cases {
+		got, err := parseReminder(c.in)
+		if err != nil {
+			if !c.wantError {
+				t.Errorf("parseReminder(%q) error %v want no error", c.in, err)
+			}
+			continue
+		}
+		if c.wantError {
+			t.Errorf("parseReminder(%q) == %v want error", c.in, got)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseReminder(%q) == %v want %v", c.in, got, c.want)
+		}
+	}
+}
+
 func TestExpandTemplate(t *testing.T) {
 	cases := []struct {
 		in    string
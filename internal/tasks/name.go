@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultNameTemplate reproduces the historical "Trip: <name>" project names.
+const DefaultNameTemplate = "Trip: {{.Name}}"
+
+// NameData is the data available to a project name template.
+type NameData struct {
+	// Name of the trip (e.g; "Lisbon").
+	Name string
+
+	Start time.Time
+	End   time.Time
+
+	// Location of the trip (e.g; "Lisbon, Portugal").
+	Location string
+}
+
+var nameFuncs = template.FuncMap{
+	"dates": formatDates,
+	"date":  func(layout string, t time.Time) string { return t.Format(layout) },
+}
+
+// ParseNameTemplate parses a project name template. Besides the fields of
+// NameData, templates may use:
+//
+//	{{dates .Start .End}}     a compact date range, e.g; "12–18 Mar"
+//	{{date "Jan 2" .Start}}   a date in Go's time.Format layout
+func ParseNameTemplate(s string) (*template.Template, error) {
+	return template.New("name").Funcs(nameFuncs).Parse(s)
+}
+
+// ExpandName expands a project name template.
+func ExpandName(t *template.Template, d NameData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// formatDates renders a date range as compactly as possible, e.g;
+// "12–18 Mar", "30 Mar–2 Apr" or "30 Dec 2025–2 Jan 2026".
+func formatDates(start, end time.Time) string {
+	sy, sm, sd := start.Date()
+	ey, em, ed := end.Date()
+
+	switch {
+	case sy != ey:
+		return fmt.Sprintf("%s–%s", start.Format("2 Jan 2006"), end.Format("2 Jan 2006"))
+	case sm != em:
+		return fmt.Sprintf("%s–%s", start.Format("2 Jan"), end.Format("2 Jan"))
+	case sd != ed:
+		return fmt.Sprintf("%d–%s", sd, end.Format("2 Jan"))
+	}
+	return start.Format("2 Jan")
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandName(t *testing.T) {
+	d := NameData{
+		Name:     "Lisbon",
+		Start:    time.Date(2024, 03, 12, 10, 00, 00, 00, time.UTC),
+		End:      time.Date(2024, 03, 18, 20, 00, 00, 00, time.UTC),
+		Location: "Lisbon, Portugal",
+	}
+
+	cases := []struct {
+		tmpl    string
+		want    string
+		wantErr bool
+	}{{
+		tmpl: DefaultNameTemplate,
+		want: "Trip: Lisbon",
+	}, {
+		tmpl: "{{.Name}} · {{dates .Start .End}}",
+		want: "Lisbon · 12–18 Mar",
+	}, {
+		tmpl: `{{.Location}} {{date "2006-01" .Start}}`,
+		want: "Lisbon, Portugal 2024-03",
+	}, {
+		tmpl:    "{{.Nmae}}",
+		wantErr: true,
+	}}
+
+	for _, c := range cases {
+		tmpl, err := ParseNameTemplate(c.tmpl)
+		if err != nil {
+			t.Errorf("ParseNameTemplate(%q) == error (%v), want no error", c.tmpl, err)
+			continue
+		}
+
+		got, err := ExpandName(tmpl, d)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ExpandName(%q) == error (%v), want error %v", c.tmpl, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("ExpandName(%q) == %q, want %q", c.tmpl, got, c.want)
+		}
+	}
+}
+
+func TestFormatDates(t *testing.T) {
+	cases := []struct {
+		start, end time.Time
+		want       string
+	}{{
+		start: time.Date(2024, 03, 12, 0, 0, 0, 0, time.UTC),
+		end:   time.Date(2024, 03, 12, 0, 0, 0, 0, time.UTC),
+		want:  "12 Mar",
+	}, {
+		start: time.Date(2024, 03, 12, 0, 0, 0, 0, time.UTC),
+		end:   time.Date(2024, 03, 18, 0, 0, 0, 0, time.UTC),
+		want:  "12–18 Mar",
+	}, {
+		start: time.Date(2024, 03, 30, 0, 0, 0, 0, time.UTC),
+		end:   time.Date(2024, 04, 02, 0, 0, 0, 0, time.UTC),
+		want:  "30 Mar–2 Apr",
+	}, {
+		start: time.Date(2025, 12, 30, 0, 0, 0, 0, time.UTC),
+		end:   time.Date(2026, 01, 02, 0, 0, 0, 0, time.UTC),
+		want:  "30 Dec 2025–2 Jan 2026",
+	}}
+
+	for _, c := range cases {
+		if got := formatDates(c.start, c.end); got != c.want {
+			t.Errorf("formatDates(%v, %v) == %q, want %q", c.start, c.end, got, c.want)
+		}
+	}
+}
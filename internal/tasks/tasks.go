@@ -3,6 +3,7 @@ package tasks
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 )
 
@@ -17,6 +18,10 @@ const (
 type Project struct {
 	Name string
 
+	// Id identifies the source of the project (e.g; a TripIt trip ID). Unlike
+	// Name, it does not change over the lifetime of the project.
+	Id string
+
 	// Tasks for this project. Order matters.
 	Tasks []Task
 
@@ -43,6 +48,49 @@ type Task struct {
 
 	// Whether or not the task is completed
 	Completed bool
+
+	// Reminders for the task, in the order given by SortReminders.
+	Reminders []Reminder
+
+	// Recurrence is set for a task that recurs in Todoist, e.g; "every day
+	// at 09:00 starting 2016-07-10 until 2016-07-17", in the timezone
+	// RecurrenceZone. Recurring tasks have no DueDateUTC.
+	Recurrence     string
+	RecurrenceZone string
+}
+
+// A Reminder notifies the user about a Task. Relative reminders fire
+// MinutesBefore the task is due; absolute reminders fire at TimeUTC.
+type Reminder struct {
+	MinutesBefore int
+
+	// Set only for absolute reminders.
+	TimeUTC time.Time
+}
+
+func (r Reminder) Absolute() bool { return !r.TimeUTC.IsZero() }
+
+func (r Reminder) String() string {
+	if r.Absolute() {
+		return fmt.Sprintf("at %s", r.TimeUTC.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%d minutes before", r.MinutesBefore)
+}
+
+// SortReminders puts reminders into a canonical order (relative reminders
+// first, then absolute ones; each earliest first) so lists of reminders from
+// different sources can be compared.
+func SortReminders(rs []Reminder) {
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		if a.Absolute() != b.Absolute() {
+			return !a.Absolute()
+		}
+		if a.Absolute() {
+			return a.TimeUTC.Before(b.TimeUTC)
+		}
+		return a.MinutesBefore > b.MinutesBefore
+	})
 }
 
 type Diff struct {
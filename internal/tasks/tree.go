@@ -0,0 +1,48 @@
+package tasks
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DueFormat is how due dates are shown to people.
+const DueFormat = "Mon 2 Jan 2006 15:04"
+
+// DateFormat is how the days of items due every day of a trip are shown.
+const DateFormat = "Mon 2 Jan"
+
+// Describe returns the task's content and its due date in loc.
+func (t Task) Describe(loc *time.Location) string {
+	if len(t.Recurrence) > 0 {
+		return fmt.Sprintf("%s (due %s in %s)", t.Content, t.Recurrence, t.RecurrenceZone)
+	}
+	if t.DueDateUTC.IsZero() {
+		return t.Content
+	}
+	return fmt.Sprintf("%s (due %s)", t.Content, t.DueDateUTC.In(loc).Format(DueFormat))
+}
+
+// WriteTree writes ts, which should be in order, as a tree:
+//
+//	. Pre-trip (due Thu 14 Jul 2016 20:00)
+//	`--- Charge Headphones (due Wed 13 Jul 2016 20:00)
+//
+// Due dates are shown in loc.
+func WriteTree(w io.Writer, ts []Task, loc *time.Location) error {
+	for _, t := range ts {
+		if _, err := fmt.Fprintln(w, TreePrefix(t.Indent)+t.Describe(loc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TreePrefix returns what precedes a task at indent in a tree.
+func TreePrefix(indent int) string {
+	if indent <= 1 {
+		return ". "
+	}
+	return strings.Repeat("    ", indent-2) + "`--- "
+}
@@ -0,0 +1,33 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTree(t *testing.T) {
+	due := time.Date(2016, 07, 14, 19, 00, 00, 00, time.UTC)
+	ts := []Task{
+		{Content: "Pre-trip", Indent: 1, DueDateUTC: due},
+		{Content: "Charge Headphones", Indent: 2, DueDateUTC: due.Add(-24 * time.Hour)},
+		{Content: "Cables", Indent: 3},
+		{Content: "Post-trip", Indent: 1},
+	}
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		t.Fatalf("Could not load timezone: %v", err)
+	}
+
+	var b strings.Builder
+	if err := WriteTree(&b, ts, dublin); err != nil {
+		t.Fatalf("WriteTree() == error (%v), want no error", err)
+	}
+	want := ". Pre-trip (due Thu 14 Jul 2016 20:00)\n" +
+		"`--- Charge Headphones (due Wed 13 Jul 2016 20:00)\n" +
+		"    `--- Cables\n" +
+		". Post-trip\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteTree() wrote\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,23 +1,24 @@
 package todoist
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/seanrees/tripist/internal/logging"
 	"github.com/seanrees/tripist/internal/tasks"
 	"github.com/twinj/uuid"
 	"golang.org/x/oauth2"
 )
 
-const (
-	ApiPath = "https://todoist.com/API/v9/sync"
-)
-
 func PTR(s string) *string { return &s }
 
 // Removes special characters from the project name.
@@ -37,24 +38,121 @@ func rewriteProjectName(s string) string {
 	return ret
 }
 
-type SyncV9API struct {
+// ProjectOptions control how projects are created in Todoist.
+type ProjectOptions struct {
+	// Parent is the name of the project to create projects under. It is
+	// created if it does not exist. If empty, projects are top-level.
+	Parent string
+
+	// Color of created projects, e.g; "blue". If empty, Todoist's default.
+	Color string
+}
+
+// syncClient implements TaskBackend on top of a Todoist sync endpoint; the
+// Sync v9 and unified APIs share the same command protocol.
+//
+// Projects may be loaded, created, updated and deleted concurrently. They
+// share one up to date read of Todoist, and each writes its own commands
+// without waiting on the others.
+type syncClient struct {
+	// mu guards links, store, stale, writes and reading. It is never held
+	// across a request to Todoist.
+	mu sync.Mutex
+
+	// parentMu is held by projects which might create the parent project
+	// (see ProjectOptions), so that it is only created once.
+	parentMu sync.Mutex
+
 	token *oauth2.Token
+
+	// path is the sync endpoint.
+	path string
+
+	// tokenParam is true if the endpoint also wants the token as a form
+	// parameter, not just an Authorization header.
+	tokenParam bool
+
+	options ProjectOptions
+
+	// links maps tasks.Project.Id to Todoist project IDs.
+	links map[string]string
+
+	// store caches reads, and is shared by every project. It is brought up
+	// to date (if stale) before use.
+	store *store
+	stale bool
+
+	// writes counts calls to Write, so a read knows if it raced with one.
+	writes int
+
+	// reading, if set, is closed when the read in progress finishes.
+	reading chan struct{}
+
+	// cacheFile, if set, persists store between runs.
+	cacheFile string
+
+	// client, if set, replaces the default oauth2 client (for tests).
+	client *http.Client
+
+	// timeout bounds each HTTP request.
+	timeout time.Duration
+
+	// transport, if set, carries requests beneath the oauth2 client.
+	transport http.RoundTripper
+}
+
+func newSyncClient(t *oauth2.Token, path string, tokenParam bool) syncClient {
+	return syncClient{
+		token:      t,
+		path:       path,
+		tokenParam: tokenParam,
+		links:      make(map[string]string),
+		stale:      true,
+		timeout:    DefaultTimeout,
+	}
 }
 
-func NewSyncV9API(t *oauth2.Token) *SyncV9API {
-	return &SyncV9API{token: t}
+// SetTimeout bounds each HTTP request to Todoist. Requests that fail are
+// retried, so an operation may take several times this long.
+func (s *syncClient) SetTimeout(d time.Duration) {
+	s.timeout = d
 }
 
-func (s *SyncV9API) makeRequest(path string, data url.Values, obj interface{}) error {
-	c := buildConfig().Client(oauth2.NoContext, s.token)
-	resp, err := c.PostForm(path, data)
+// SetTransport carries requests to Todoist over t, e.g; to instrument them.
+func (s *syncClient) SetTransport(t http.RoundTripper) {
+	s.transport = t
+}
 
-	if err != nil {
-		return err
+// SetCacheFile keeps reads from Todoist in filename, so later runs only need
+// to read what has changed since.
+func (s *syncClient) SetCacheFile(filename string) {
+	s.cacheFile = filename
+	s.store = nil
+	s.stale = true
+}
+
+func (s *syncClient) SetProjectOptions(o ProjectOptions) {
+	s.options = o
+}
+
+// SetLinks sets the table linking tasks.Project.Id to Todoist project IDs.
+// The table is updated in place as projects are found and created, so the
+// caller should persist it between runs.
+func (s *syncClient) SetLinks(l map[string]string) {
+	s.links = l
+}
+
+func (s *syncClient) makeRequest(ctx context.Context, path string, data url.Values, obj interface{}) error {
+	c := s.client
+	if c == nil {
+		// API requests only need the user's token; the application's keys
+		// are used to obtain it (see Authorize).
+		base := &http.Client{Transport: logging.Transport("todoist", s.transport)}
+		c = oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, base), oauth2.StaticTokenSource(s.token))
+		c.Timeout = s.timeout
 	}
-	defer resp.Body.Close()
 
-	j, err := ioutil.ReadAll(resp.Body)
+	j, err := postWithRetry(ctx, c, path, data)
 	if err != nil {
 		return err
 	}
@@ -66,13 +164,24 @@ func (s *SyncV9API) makeRequest(path string, data url.Values, obj interface{}) e
 	return nil
 }
 
+func (s *syncClient) params() url.Values {
+	params := url.Values{}
+	if s.tokenParam {
+		params.Add("token", s.token.AccessToken)
+	}
+	return params
+}
+
 // Reads specific types and returns a ReadResponse. Possible types are in constants:
-// Projects, Items.
-func (s *SyncV9API) Read(types []string) (ReadResponse, error) {
+// Projects, Items, etc. An empty syncToken reads everything; otherwise only
+// changes since the read that returned syncToken.
+func (s *syncClient) Read(ctx context.Context, syncToken string, types []string) (ReadResponse, error) {
 	resp := ReadResponse{}
-	params := url.Values{}
-	params.Add("token", s.token.AccessToken)
-	params.Add("sync_token", "*")
+	params := s.params()
+	if len(syncToken) == 0 {
+		syncToken = "*"
+	}
+	params.Add("sync_token", syncToken)
 
 	t, err := json.Marshal(types)
 	if err != nil {
@@ -80,41 +189,107 @@ func (s *SyncV9API) Read(types []string) (ReadResponse, error) {
 	}
 	params.Add("resource_types", string(t))
 
-	if err := s.makeRequest(ApiPath, params, &resp); err != nil {
+	if err := s.makeRequest(ctx, s.path, params, &resp); err != nil {
 		return resp, err
 	}
 	return resp, nil
 }
 
-func (s *SyncV9API) Write(c Commands) (WriteResponse, error) {
+// Write runs commands against Todoist. Commands are sent in batches of at most
+// MaxCommandsPerRequest; temp_ids created by one batch are resolved to real IDs
+// in later ones. If any command fails, the error is a *WriteError.
+func (s *syncClient) Write(ctx context.Context, c Commands) (WriteResponse, error) {
+	resp := WriteResponse{
+		TempIdMapping: make(map[string]string),
+		SyncStatus:    make(map[string]interface{}),
+	}
+	werr := &WriteError{Total: len(c)}
+
+	// Whatever happens, our store will no longer reflect Todoist.
+	defer func() {
+		s.mu.Lock()
+		s.stale = true
+		s.writes++
+		s.mu.Unlock()
+	}()
+
+	for start := 0; start < len(c); start += MaxCommandsPerRequest {
+		end := start + MaxCommandsPerRequest
+		if end > len(c) {
+			end = len(c)
+		}
+		batch := resolveTempIds(c[start:end], resp.TempIdMapping)
+
+		br, err := s.writeBatch(ctx, batch)
+		if err != nil {
+			return resp, err
+		}
+
+		for k, v := range br.TempIdMapping {
+			resp.TempIdMapping[k] = v
+		}
+		for k, v := range br.SyncStatus {
+			resp.SyncStatus[k] = v
+		}
+		resp.SequenceNumber = br.SequenceNumber
+
+		werr.Failed = append(werr.Failed, s.checkErrors(&batch, &br)...)
+	}
+
+	if len(werr.Failed) > 0 {
+		for _, e := range werr.Failed {
+			slog.WarnContext(ctx, "Todoist command failed", commandAttrs(e.Item, slog.String("error", e.Message))...)
+		}
+		return resp, werr
+	}
+
+	return resp, nil
+}
+
+func (s *syncClient) writeBatch(ctx context.Context, c Commands) (WriteResponse, error) {
 	resp := WriteResponse{}
-	params := url.Values{}
-	params.Add("token", s.token.AccessToken)
+	params := s.params()
 
 	cmds, err := json.Marshal(c)
 	if err != nil {
-		return resp, nil
+		return resp, err
 	}
 	params.Add("commands", string(cmds))
 
-	log.Printf("Writing %d commands to Todoist", len(c))
-
-	if err := s.makeRequest(ApiPath, params, &resp); err != nil {
-		return resp, err
+	slog.InfoContext(ctx, "Writing to Todoist", "commands", len(c))
+	for _, i := range c {
+		slog.DebugContext(ctx, "Todoist command", commandAttrs(i)...)
 	}
 
-	if errs := s.checkErrors(&c, &resp); len(errs) > 0 {
-		for _, e := range errs {
-			log.Printf("Write error from Todoist: %s", e.Message)
+	// Retries resend the same command UUIDs, which Todoist will not apply
+	// twice.
+	err = s.makeRequest(ctx, s.path, params, &resp)
+	return resp, err
+}
+
+// commandAttrs describes a command for logging, so that its outcome can be
+// traced by UUID.
+func commandAttrs(c WriteItem, attrs ...any) []any {
+	if c.Type != nil {
+		attrs = append(attrs, slog.String("type", *c.Type))
+	}
+	if c.UUID != nil {
+		attrs = append(attrs, slog.String("uuid", *c.UUID))
+	}
+	if c.TempId != nil {
+		attrs = append(attrs, slog.String("temp_id", *c.TempId))
+	}
+	if i, ok := c.Args.(Item); ok && i.Content != nil {
+		attrs = append(attrs, slog.String("content", *i.Content))
+		if i.Due != nil {
+			attrs = append(attrs, slog.String("due", i.Due.Date))
 		}
-		return resp, fmt.Errorf("write failed for %d/%d commands, call checkErrors", len(errs), len(c))
 	}
-
-	return resp, nil
+	return attrs
 }
 
-func (s *SyncV9API) checkErrors(cmds *Commands, r *WriteResponse) []writeError {
-	var ret []writeError
+func (s *syncClient) checkErrors(cmds *Commands, r *WriteResponse) []CommandError {
+	var ret []CommandError
 	uuidTbl := make(map[string]WriteItem)
 	for _, c := range *cmds {
 		uuidTbl[*c.UUID] = c
@@ -164,51 +339,215 @@ func (s *SyncV9API) checkErrors(cmds *Commands, r *WriteResponse) []writeError {
 
 		if len(msg) > 0 {
 			msg += fmt.Sprintf(" (uuid %s)", uuid)
-			ret = append(ret, writeError{Message: msg, Item: c})
+			ret = append(ret, CommandError{Message: msg, Item: c})
 		}
 	}
 
 	return ret
 }
 
-func (s *SyncV9API) listItems(p *Project) ([]Item, error) {
-	resp, err := s.Read([]string{Items})
+// sync brings the store up to date if it is stale: with a full read the
+// first time, and incremental reads after that. Concurrent callers share one
+// read.
+func (s *syncClient) sync(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if s.store == nil {
+			s.store = newStore()
+			if len(s.cacheFile) > 0 {
+				st, err := readStore(s.cacheFile)
+				if err != nil {
+					slog.WarnContext(ctx, "Could not read Todoist cache, doing a full read", "file", s.cacheFile, "error", err)
+				}
+				s.store = st
+			}
+		}
+		if !s.stale {
+			s.mu.Unlock()
+			return nil
+		}
+		if r := s.reading; r != nil {
+			s.mu.Unlock()
+			select {
+			case <-r:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
 
-	if err != nil {
-		log.Printf("Could not read Todoist items: %v", err)
-		return nil, err
+		r := make(chan struct{})
+		s.reading = r
+		token, writes := s.store.SyncToken, s.writes
+		s.mu.Unlock()
+
+		resp, err := s.Read(ctx, token, storeTypes)
+
+		s.mu.Lock()
+		s.reading = nil
+		close(r)
+		if err != nil {
+			s.mu.Unlock()
+			slog.WarnContext(ctx, "Could not read from Todoist", "error", err)
+			return err
+		}
+		s.store.apply(resp)
+		// A write that finished during the read may not be in it.
+		s.stale = s.writes != writes
+
+		slog.InfoContext(ctx, "Read from Todoist", "full", resp.FullSync, "items_changed", len(resp.Items), "projects_changed", len(resp.Projects))
+
+		if len(s.cacheFile) > 0 {
+			if err := writeStore(s.store, s.cacheFile); err != nil {
+				slog.WarnContext(ctx, "Could not write Todoist cache", "file", s.cacheFile, "error", err)
+			}
+		}
+		s.mu.Unlock()
+		return nil
+	}
+}
+
+// listItemsAndReminders returns the items in a project and their reminders,
+// keyed by item ID. The caller holds mu.
+func (s *syncClient) listItemsAndReminders(ctx context.Context, p *Project) ([]Item, map[string][]Reminder) {
+	ret := s.store.projectItems(*p.Id)
+	ids := make(map[string]bool)
+	for _, i := range ret {
+		ids[*i.Id] = true
 	}
 
-	var ret []Item
-	for _, i := range resp.Items {
-		if *i.ProjectId == *p.Id {
-			ret = append(ret, i)
+	rems := make(map[string][]Reminder)
+	for _, r := range s.store.Reminders {
+		if r.ItemId == nil || !ids[*r.ItemId] {
+			continue
 		}
+		rems[*r.ItemId] = append(rems[*r.ItemId], r)
+	}
+	for _, rs := range rems {
+		sort.Slice(rs, func(a, b int) bool { return *rs[a].Id < *rs[b].Id })
 	}
 
-	log.Printf("Loaded %d items (%d for this project) from Todoist", len(resp.Items), len(ret))
+	slog.DebugContext(ctx, "Loaded items from Todoist", "items", len(s.store.Items), "project_items", len(ret))
 
-	return ret, nil
+	return ret, rems
 }
 
-func (s *SyncV9API) findProject(name string) (*Project, error) {
-	resp, err := s.Read([]string{Projects})
-	if err != nil {
-		log.Printf("Could not read Todoist projects: %v", err)
-		return nil, err
+// listProjects returns all live projects and their notes. The caller holds mu.
+func (s *syncClient) listProjects() ([]Project, []ProjectNote) {
+	var ret []Project
+	for _, p := range s.store.Projects {
+		if p.Name == nil {
+			continue
+		}
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(a, b int) bool { return *ret[a].Id < *ret[b].Id })
+
+	var notes []ProjectNote
+	for _, n := range s.store.ProjectNotes {
+		if n.ProjectId == nil || n.Content == nil {
+			continue
+		}
+		notes = append(notes, n)
 	}
+	sort.Slice(notes, func(a, b int) bool { return *notes[a].Id < *notes[b].Id })
+	return ret, notes
+}
 
+func findProjectByName(ps []Project, name string) *Project {
 	rp := rewriteProjectName(name)
-	for _, p := range resp.Projects {
+	for _, p := range ps {
 		if *p.Name == rp {
-			log.Printf("Found existing project %q id=%v", *p.Name, *p.Id)
-			return &p, nil
+			return &p
 		}
 	}
-	return nil, nil
+	return nil
 }
 
-func (s *SyncV9API) createProject(name, tempId string) WriteItem {
+func findProjectById(ps []Project, id string) *Project {
+	for _, p := range ps {
+		if *p.Id == id {
+			return &p
+		}
+	}
+	return nil
+}
+
+// Projects are linked to their tasks.Project.Id by a note on the project, so
+// the link survives renames and can be recovered without local state.
+const linkNotePrefix = "tripist-link: "
+
+func linkNote(id string) string { return linkNotePrefix + id }
+
+// linkedIds returns the tasks.Project.Id each project is linked to by note,
+// keyed by Todoist project ID.
+func linkedIds(notes []ProjectNote) map[string]string {
+	ret := make(map[string]string)
+	for _, n := range notes {
+		if id, ok := strings.CutPrefix(*n.Content, linkNotePrefix); ok {
+			ret[*n.ProjectId] = strings.TrimSpace(id)
+		}
+	}
+	return ret
+}
+
+// lookupProject finds the project for tasks.Project id. Projects are found
+// through the links table, then by link note. Projects that predate both are
+// found by name, but only if they are not already linked to something else.
+// The caller holds mu.
+func (s *syncClient) lookupProject(ctx context.Context, ps []Project, notes []ProjectNote, id, name string) *Project {
+	if pid, ok := s.links[id]; ok {
+		if p := findProjectById(ps, pid); p != nil {
+			slog.InfoContext(ctx, "Found linked project", "project", *p.Name, "project_id", *p.Id)
+			return p
+		}
+		slog.InfoContext(ctx, "Linked project no longer exists", "project_id", pid)
+		delete(s.links, id)
+	}
+
+	byNote := linkedIds(notes)
+	for pid, lid := range byNote {
+		if lid != id {
+			continue
+		}
+		if p := findProjectById(ps, pid); p != nil {
+			slog.InfoContext(ctx, "Recovered link to project", "project", *p.Name, "project_id", *p.Id)
+			s.links[id] = pid
+			return p
+		}
+	}
+
+	p := findProjectByName(ps, name)
+	if p == nil {
+		return nil
+	}
+	if lid, ok := byNote[*p.Id]; ok && lid != id {
+		slog.InfoContext(ctx, "Project with this name belongs to another trip", "project", *p.Name, "project_id", *p.Id, "owner", lid)
+		return nil
+	}
+	for lid, pid := range s.links {
+		if pid == *p.Id && lid != id {
+			slog.InfoContext(ctx, "Project with this name belongs to another trip", "project", *p.Name, "project_id", *p.Id, "owner", lid)
+			return nil
+		}
+	}
+
+	slog.InfoContext(ctx, "Found existing project by name", "project", *p.Name, "project_id", *p.Id)
+	if len(id) > 0 {
+		s.links[id] = *p.Id
+	}
+	return p
+}
+
+func (s *syncClient) addLinkNote(projId, id string) WriteItem {
+	return WriteItem{
+		Type:   PTR(NoteAdd),
+		TempId: PTR(uuid.NewV4().String()),
+		UUID:   PTR(uuid.NewV4().String()),
+		Args:   ProjectNote{ProjectId: &projId, Content: PTR(linkNote(id))}}
+}
+
+func (s *syncClient) createProject(name, tempId string) WriteItem {
 	return WriteItem{
 		Type:   PTR(ProjectAdd),
 		TempId: PTR(tempId),
@@ -216,16 +555,48 @@ func (s *SyncV9API) createProject(name, tempId string) WriteItem {
 		Args:   Project{Name: PTR(rewriteProjectName(name))}}
 }
 
-func (s *SyncV9API) deleteProject(p *Project) WriteItem {
+func (s *syncClient) renameProject(id, name string) WriteItem {
+	return WriteItem{
+		Type: PTR(ProjectUpdate),
+		UUID: PTR(uuid.NewV4().String()),
+		Args: Project{Id: &id, Name: PTR(rewriteProjectName(name))}}
+}
+
+// createProjectWithOptions returns the commands to create a project named
+// name (with tempId) according to the ProjectOptions, including its parent if
+// that does not exist yet.
+func (s *syncClient) createProjectWithOptions(ctx context.Context, ps []Project, name, tempId string) Commands {
+	var cmds Commands
+	c := s.createProject(name, tempId)
+	args := c.Args.(Project)
+
+	if len(s.options.Color) > 0 {
+		args.Color = PTR(s.options.Color)
+	}
+
+	if len(s.options.Parent) > 0 {
+		if parent := findProjectByName(ps, s.options.Parent); parent != nil {
+			args.ParentId = parent.Id
+		} else {
+			slog.InfoContext(ctx, "Creating parent project", "project", s.options.Parent)
+			pc := s.createProject(s.options.Parent, uuid.NewV4().String())
+			cmds = append(cmds, pc)
+			args.ParentId = pc.TempId
+		}
+	}
+
+	c.Args = args
+	return append(cmds, c)
+}
+
+func (s *syncClient) deleteProject(p *Project) WriteItem {
 	return WriteItem{
 		Type: PTR(ProjectDelete),
 		UUID: PTR(uuid.NewV4().String()),
 		Args: IdContainer{Id: *p.Id}}
 }
 
-func (s *SyncV9API) createItem(projId string, parent *string, t tasks.Task) WriteItem {
-	log.Printf("Creating task %q (pos=%d) due %s", t.Content, t.Position, t.DueDateUTC.Format(time.RFC3339))
-
+func (s *syncClient) createItem(projId string, parent *string, t tasks.Task) WriteItem {
 	// Todoist does not deal well with ItemOrder = 0; it won't honour order with
 	// something at zero.
 	pos := t.Position + 1
@@ -238,22 +609,16 @@ func (s *SyncV9API) createItem(projId string, parent *string, t tasks.Task) Writ
 			Content:    &t.Content,
 			ParentId:   parent,
 			ChildOrder: &pos,
-			Due: &Due{
-				Date:     t.DueDateUTC.Format(time.RFC3339),
-				Timezone: PTR("UTC"),
-			},
-			ProjectId: &projId}}
+			Due:        taskDue(t),
+			ProjectId:  &projId}}
 }
 
-func (s *SyncV9API) updateItem(i Item, t tasks.Task) WriteItem {
-	log.Printf("Updating task %q (pos=%d) due %s", t.Content, t.Position, t.DueDateUTC.Format(time.RFC3339))
-
+func (s *syncClient) updateItem(i Item, t tasks.Task) WriteItem {
 	// Todoist does not deal well with ItemOrder = 0; it won't honour order with
 	// something at zero.
 	pos := t.Position + 1
 
-	i.Due.Date = t.DueDateUTC.Format(time.RFC3339)
-	i.Due.Timezone = PTR("UTC")
+	i.Due = taskDue(t)
 	i.ChildOrder = &pos
 
 	return WriteItem{
@@ -263,91 +628,301 @@ func (s *SyncV9API) updateItem(i Item, t tasks.Task) WriteItem {
 		Args:   i}
 }
 
-func (s *SyncV9API) deleteItem(i Item) WriteItem {
+// taskDue returns the due date of t: its recurrence, if it has one, or its
+// due date in UTC.
+func taskDue(t tasks.Task) *Due {
+	if len(t.Recurrence) > 0 {
+		return &Due{
+			String:      t.Recurrence,
+			Timezone:    PTR(t.RecurrenceZone),
+			IsRecurring: true,
+			Language:    "en",
+		}
+	}
+	return &Due{
+		Date:     t.DueDateUTC.Format(time.RFC3339),
+		Timezone: PTR("UTC"),
+	}
+}
+
+func (s *syncClient) createReminder(itemId string, r tasks.Reminder) WriteItem {
+	return WriteItem{
+		Type:   PTR(ReminderAdd),
+		TempId: PTR(uuid.NewV4().String()),
+		UUID:   PTR(uuid.NewV4().String()),
+		Args:   reminderArgs(Reminder{ItemId: &itemId}, r)}
+}
+
+func (s *syncClient) updateReminder(rem Reminder, r tasks.Reminder) WriteItem {
+	return WriteItem{
+		Type: PTR(ReminderUpdate),
+		UUID: PTR(uuid.NewV4().String()),
+		Args: reminderArgs(Reminder{Id: rem.Id}, r)}
+}
+
+func (s *syncClient) deleteReminder(rem Reminder) WriteItem {
+	return WriteItem{
+		Type: PTR(ReminderDelete),
+		UUID: PTR(uuid.NewV4().String()),
+		Args: IdContainer{Id: *rem.Id}}
+}
+
+// reminderArgs fills in the type and timing of rem from r.
+func reminderArgs(rem Reminder, r tasks.Reminder) Reminder {
+	if r.Absolute() {
+		rem.Type = PTR(ReminderAbsolute)
+		rem.Due = &Due{
+			Date:     r.TimeUTC.Format(time.RFC3339),
+			Timezone: PTR("UTC"),
+		}
+	} else {
+		rem.Type = PTR(ReminderRelative)
+		rem.MinuteOffset = &r.MinutesBefore
+	}
+	return rem
+}
+
+// taskReminders converts Todoist reminders into tasks.Reminders. Reminders
+// which cannot be represented (e.g; location reminders) are ignored.
+func taskReminders(rems []Reminder) []tasks.Reminder {
+	var ret []tasks.Reminder
+	for _, r := range rems {
+		switch {
+		case r.Type == nil:
+			continue
+
+		case *r.Type == ReminderRelative && r.MinuteOffset != nil:
+			ret = append(ret, tasks.Reminder{MinutesBefore: *r.MinuteOffset})
+
+		case *r.Type == ReminderAbsolute && r.Due != nil:
+			t, err := time.Parse(time.RFC3339, r.Due.Date)
+			if err != nil {
+				slog.Warn("Could not parse reminder, ignoring (may generate diffs)", "due", r.Due.Date, "error", err)
+				continue
+			}
+			ret = append(ret, tasks.Reminder{TimeUTC: t.UTC()})
+		}
+	}
+	tasks.SortReminders(ret)
+	return ret
+}
+
+// syncReminders returns the commands to make the reminders on an item match
+// want. Existing reminders are updated in place where possible.
+func (s *syncClient) syncReminders(itemId string, have []Reminder, want []tasks.Reminder) Commands {
+	var cmds Commands
+	if reflect.DeepEqual(taskReminders(have), want) {
+		return cmds
+	}
+
+	for idx, r := range want {
+		if idx < len(have) {
+			cmds = append(cmds, s.updateReminder(have[idx], r))
+		} else {
+			cmds = append(cmds, s.createReminder(itemId, r))
+		}
+	}
+	for idx := len(want); idx < len(have); idx++ {
+		cmds = append(cmds, s.deleteReminder(have[idx]))
+	}
+	return cmds
+}
+
+func (s *syncClient) deleteItem(i Item) WriteItem {
 	return WriteItem{
 		Type: PTR(ItemDelete),
 		UUID: PTR(uuid.NewV4().String()),
 		Args: IdContainer{Id: *i.Id}}
 }
 
-// Returns a tasks.Project, whether or not it was found, and any error.
-func (s *SyncV9API) LoadProject(name string) (tasks.Project, bool, error) {
-	ret := tasks.Project{Name: name}
-	found := false
+// LoadProject loads the project for id (see tasks.Project.Id), which should
+// be called name. Returns a tasks.Project, whether or not it was found, and any
+// error.
+func (s *syncClient) LoadProject(ctx context.Context, id, name string) (tasks.Project, bool, error) {
+	ret := tasks.Project{Name: name, Id: id}
 
-	p, err := s.findProject(name)
-	switch {
-	case err != nil:
-		return ret, found, err
-	case p == nil:
-		return ret, found, nil
+	if err := s.sync(ctx); err != nil {
+		return ret, false, err
 	}
-	found = true
 
-	li, err := s.listItems(p)
-	if err != nil {
-		return ret, found, err
+	s.mu.Lock()
+	ps, notes := s.listProjects()
+	p := s.lookupProject(ctx, ps, notes, id, name)
+	if p == nil {
+		s.mu.Unlock()
+		return ret, false, nil
 	}
+	ctx = logging.With(ctx, "project_id", *p.Id)
+	li, rems := s.listItemsAndReminders(ctx, p)
+	s.mu.Unlock()
 
-	parents := []string{"0"}
+	parents := hierarchy{"0"}
 
 	for _, i := range li {
 		if !i.Valid() {
-			log.Printf("Ignoring invalid item: %s", i)
+			slog.WarnContext(ctx, "Ignoring invalid item", "item", i.String())
 			continue
 		}
 
 		var due time.Time
+		var recurrence, zone string
 		if i.Due == nil {
-			log.Printf("No due date for %q, using empty value", *i.Content)
+			slog.DebugContext(ctx, "No due date, using empty value", "content", *i.Content)
+		} else if i.Due.IsRecurring {
+			// Recurring tasks are compared by their recurrence; their
+			// date moves on as they are completed.
+			recurrence = tasks.NormalizeRecurrence(i.Due.String)
+			if i.Due.Timezone != nil {
+				zone = *i.Due.Timezone
+			}
 		} else {
+			var err error
 			due, err = time.ParseInLocation(time.RFC3339, i.Due.Date, time.UTC)
 			if err != nil {
-				log.Printf("Could not parse %q: %v (ignoring, may generate diffs)", i.Due.Date, err)
+				slog.WarnContext(ctx, "Could not parse due date, ignoring (may generate diffs)", "content", *i.Content, "due", i.Due.Date, "error", err)
 			}
 		}
 
-		// Remapping task hierarchy onto indentation levels.
-		indent := 0
-		if i.ParentId != nil {
-			for idx, id := range parents {
-				if id == *i.ParentId {
-					indent = idx + 1
-				}
-			}
-			for len(parents) < indent+1 {
-				parents = append(parents, "0")
-			}
-			parents[indent] = *i.Id
-		} else {
-			parents[0] = *i.Id
-		}
+		indent := parents.indent(i)
 
 		ret.Tasks = append(ret.Tasks, tasks.Task{
 			Content:    *i.Content,
 			DueDateUTC: due,
 			// For historical reasons in Todoist, we're 1-based.
-			Indent:    indent + 1,
-			Completed: *i.Checked,
-			Position:  (*i.ChildOrder) - 1})
+			Indent:         indent + 1,
+			Completed:      *i.Checked,
+			Position:       (*i.ChildOrder) - 1,
+			Reminders:      taskReminders(rems[*i.Id]),
+			Recurrence:     recurrence,
+			RecurrenceZone: zone})
+	}
+
+	ret.External = &projectItems{
+		ProjectId: *p.Id,
+		Name:      *p.Name,
+		Linked:    linkedIds(notes)[*p.Id] == id,
+		Items:     li,
+		Reminders: rems,
 	}
 
-	ret.External = &projectItems{ProjectId: *p.Id, Items: li}
+	return ret, true, nil
+}
+
+// ProjectName returns the name in Todoist of p, loaded by LoadProject. This
+// differs from p.Name when the project was renamed or linked by hand.
+func ProjectName(p tasks.Project) string {
+	if tp, ok := p.External.(*projectItems); ok {
+		return tp.Name
+	}
+	return p.Name
+}
 
-	return ret, found, nil
+// hierarchy remaps Todoist's task hierarchy onto indentation levels, for
+// items visited in project order.
+type hierarchy []string
+
+// indent returns the 0-based indentation of i, and makes i the parent of the
+// items that follow it one level deeper.
+func (h *hierarchy) indent(i Item) int {
+	indent := 0
+	if i.ParentId != nil {
+		for idx, id := range *h {
+			if id == *i.ParentId {
+				indent = idx + 1
+			}
+		}
+		for len(*h) < indent+1 {
+			*h = append(*h, "0")
+		}
+	}
+	(*h)[indent] = *i.Id
+	return indent
 }
 
-func (s *SyncV9API) CreateProject(p tasks.Project) error {
+func (s *syncClient) CreateProject(ctx context.Context, p tasks.Project) error {
 	tempId := uuid.NewV4().String()
 
-	cmds := Commands{s.createProject(p.Name, tempId)}
-	cmds = append(cmds, s.addTasks(tempId, p.Tasks)...)
+	// Until the parent project is known to exist, creations take turns: the
+	// first creates it, and the rest read it back.
+	unlockParent := func() {}
+	if len(s.options.Parent) > 0 {
+		s.parentMu.Lock()
+		unlockParent = sync.OnceFunc(s.parentMu.Unlock)
+		defer unlockParent()
+	}
+
+	if err := s.sync(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	ps, _ := s.listProjects()
+	s.mu.Unlock()
+	if findProjectByName(ps, s.options.Parent) != nil {
+		unlockParent()
+	}
+
+	ctx = logging.With(ctx, "project_temp_id", tempId)
+	slog.InfoContext(ctx, "Creating project", "project", p.Name, "tasks", len(p.Tasks))
+	cmds := s.createProjectWithOptions(ctx, ps, p.Name, tempId)
+	if len(p.Id) > 0 {
+		cmds = append(cmds, s.addLinkNote(tempId, p.Id))
+	}
+	cmds = append(cmds, s.addTasks(tempId, nil, p.Tasks)...)
+
+	resp, err := s.Write(ctx, cmds)
+	if err != nil {
+		return err
+	}
+
+	if id, ok := resp.TempIdMapping[tempId]; ok {
+		slog.InfoContext(ctx, "Created project", "project_id", id)
+		if len(p.Id) > 0 {
+			s.mu.Lock()
+			s.links[p.Id] = id
+			s.mu.Unlock()
+		}
+	}
+	return nil
+}
 
-	_, err := s.Write(cmds)
-	return err
+// placedItem is an item already in a project, at its indentation and
+// position.
+type placedItem struct {
+	id       string
+	indent   int
+	position int
 }
 
-func (s *SyncV9API) addTasks(tempId string, ts []tasks.Task) Commands {
+// placedItems returns the valid items of tp in order of position. Items
+// changed by diffs take their new position.
+func placedItems(tp *projectItems, diffs []tasks.Diff) []placedItem {
+	moved := map[string]int{}
+	for _, d := range diffs {
+		if d.Type == tasks.Changed {
+			moved[d.Task.Content] = d.Task.Position
+		}
+	}
+
+	var ret []placedItem
+	parents := hierarchy{"0"}
+	for _, i := range tp.Items {
+		if !i.Valid() {
+			continue
+		}
+		pos, ok := moved[*i.Content]
+		if !ok {
+			pos = *i.ChildOrder - 1
+		}
+		ret = append(ret, placedItem{id: *i.Id, indent: parents.indent(i) + 1, position: pos})
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].position < ret[j].position })
+	return ret
+}
+
+// addTasks adds ts to the project tempId, beneath the parents they have
+// among existing items and ts.
+func (s *syncClient) addTasks(tempId string, existing []placedItem, ts []tasks.Task) Commands {
 	var cmds Commands
 
 	// Support indent -> parentId conversion.
@@ -357,27 +932,72 @@ func (s *SyncV9API) addTasks(tempId string, ts []tasks.Task) Commands {
 			max = t.Indent
 		}
 	}
+	for _, e := range existing {
+		if e.indent > max {
+			max = e.indent
+		}
+	}
 
 	parents := make([]*string, max+1)
 	for _, t := range ts {
+		// Existing items before t may be its parent.
+		for len(existing) > 0 && existing[0].position <= t.Position {
+			parents[existing[0].indent] = &existing[0].id
+			existing = existing[1:]
+		}
+
 		i := s.createItem(tempId, parents[t.Indent-1], t)
 		parents[t.Indent] = i.TempId
 
 		cmds = append(cmds, i)
+
+		// Reminders refer to the item by its temp_id, which Todoist maps
+		// within the same batch of commands.
+		for _, r := range t.Reminders {
+			cmds = append(cmds, s.createReminder(*i.TempId, r))
+		}
 	}
 
 	return cmds
 }
 
-func (s *SyncV9API) UpdateProject(p tasks.Project, diffs []tasks.Diff) error {
+// DeleteProject deletes a project loaded by LoadProject, along with its tasks.
+func (s *syncClient) DeleteProject(ctx context.Context, p tasks.Project) error {
 	tp, ok := p.External.(*projectItems)
 	if !ok {
 		return fmt.Errorf("missing or invalid external project pointer on %q", p.Name)
 	}
 
+	if _, err := s.Write(ctx, Commands{s.deleteProject(&Project{Id: &tp.ProjectId})}); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.links, p.Id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *syncClient) UpdateProject(ctx context.Context, p tasks.Project, diffs []tasks.Diff) error {
+	tp, ok := p.External.(*projectItems)
+	if !ok {
+		return fmt.Errorf("missing or invalid external project pointer on %q", p.Name)
+	}
+
+	ctx = logging.With(ctx, "project_id", tp.ProjectId)
+
 	var cmds Commands
 	var adds []tasks.Task
 
+	if rewriteProjectName(p.Name) != tp.Name {
+		slog.InfoContext(ctx, "Renaming project", "from", tp.Name, "to", p.Name)
+		cmds = append(cmds, s.renameProject(tp.ProjectId, p.Name))
+	}
+
+	if !tp.Linked && len(p.Id) > 0 {
+		slog.InfoContext(ctx, "Linking project", "project", tp.Name)
+		cmds = append(cmds, s.addLinkNote(tp.ProjectId, p.Id))
+	}
+
 	for _, d := range diffs {
 		switch d.Type {
 		case tasks.Added:
@@ -387,21 +1007,22 @@ func (s *SyncV9API) UpdateProject(p tasks.Project, diffs []tasks.Diff) error {
 			for _, i := range tp.Items {
 				if *i.Content == d.Task.Content {
 					cmds = append(cmds, s.updateItem(i, d.Task))
+					cmds = append(cmds, s.syncReminders(*i.Id, tp.Reminders[*i.Id], d.Task.Reminders)...)
 					break
 				}
 			}
 		case tasks.Removed:
-			log.Printf("Not removing missing task: %q", d.Task.Content)
+			slog.InfoContext(ctx, "Not removing missing task", "content", d.Task.Content)
 		}
 	}
 
-	cmds = append(cmds, s.addTasks(tp.ProjectId, adds)...)
+	cmds = append(cmds, s.addTasks(tp.ProjectId, placedItems(tp, diffs), adds)...)
 
 	if len(cmds) > 0 {
-		_, err := s.Write(cmds)
+		_, err := s.Write(ctx, cmds)
 		return err
 	} else {
-		log.Printf("No commands to run to update project %q", p.Name)
+		slog.InfoContext(ctx, "Project is up to date", "project", p.Name)
 	}
 
 	return nil
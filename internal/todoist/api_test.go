@@ -1,8 +1,16 @@
 package todoist
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
 )
 
 func TestRewriteProjectName(t *testing.T) {
@@ -109,3 +117,404 @@ func TestCheckErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestAddTasksReminders(t *testing.T) {
+	api := NewSyncV9API(nil)
+	ts := []tasks.Task{
+		{Content: "one", Indent: 1, Reminders: []tasks.Reminder{{MinutesBefore: 30}}},
+		{Content: "two", Indent: 2, Reminders: []tasks.Reminder{
+			{MinutesBefore: 10},
+			{TimeUTC: time.Date(2016, 07, 14, 0, 0, 0, 0, time.UTC)},
+		}},
+	}
+
+	cmds := api.addTasks("project", nil, ts)
+	wantTypes := []string{ItemAdd, ReminderAdd, ItemAdd, ReminderAdd, ReminderAdd}
+	if got, want := len(cmds), len(wantTypes); got != want {
+		t.Fatalf("len(addTasks()) == %d, want %d", got, want)
+	}
+
+	var item *string
+	for i, c := range cmds {
+		if *c.Type != wantTypes[i] {
+			t.Errorf("addTasks()[%d].Type == %q, want %q", i, *c.Type, wantTypes[i])
+			continue
+		}
+		if *c.Type == ItemAdd {
+			item = c.TempId
+			continue
+		}
+
+		r := c.Args.(Reminder)
+		if *r.ItemId != *item {
+			t.Errorf("addTasks()[%d] reminder for item %q, want temp_id %q", i, *r.ItemId, *item)
+		}
+	}
+
+	if r := cmds[4].Args.(Reminder); *r.Type != ReminderAbsolute || r.Due.Date != "2016-07-14T00:00:00Z" {
+		t.Errorf("addTasks()[4] == %v, want absolute reminder at 2016-07-14T00:00:00Z", r)
+	}
+}
+
+func TestSyncReminders(t *testing.T) {
+	api := NewSyncV9API(nil)
+	thirty := 30
+	have := []Reminder{
+		{Id: PTR("r1"), ItemId: PTR("i1"), Type: PTR(ReminderRelative), MinuteOffset: &thirty},
+		{Id: PTR("r2"), ItemId: PTR("i1"), Type: PTR(ReminderAbsolute), Due: &Due{Date: "2016-07-14T00:00:00Z"}},
+	}
+	abs := tasks.Reminder{TimeUTC: time.Date(2016, 07, 14, 0, 0, 0, 0, time.UTC)}
+
+	cases := []struct {
+		want  []tasks.Reminder
+		types []string
+	}{{
+		want: []tasks.Reminder{{MinutesBefore: 30}, abs},
+	}, {
+		want:  []tasks.Reminder{{MinutesBefore: 60}, abs},
+		types: []string{ReminderUpdate, ReminderUpdate},
+	}, {
+		want:  []tasks.Reminder{{MinutesBefore: 30}, {MinutesBefore: 10}, abs},
+		types: []string{ReminderUpdate, ReminderUpdate, ReminderAdd},
+	}, {
+		types: []string{ReminderDelete, ReminderDelete},
+	}}
+
+	for _, c := range cases {
+		var got []string
+		for _, cmd := range api.syncReminders("i1", have, c.want) {
+			got = append(got, *cmd.Type)
+		}
+		if !reflect.DeepEqual(got, c.types) {
+			t.Errorf("syncReminders(%v) == %v, want %v", c.want, got, c.types)
+		}
+	}
+}
+
+func TestReminderArgsJSON(t *testing.T) {
+	api := NewSyncV9API(nil)
+	abs := tasks.Reminder{TimeUTC: time.Date(2016, 07, 14, 0, 0, 0, 0, time.UTC)}
+
+	cases := []struct {
+		cmd  WriteItem
+		want string
+	}{
+		{api.createReminder("i1", tasks.Reminder{MinutesBefore: 30}), `{"item_id":"i1","type":"relative","minute_offset":30}`},
+		{api.createReminder("i1", tasks.Reminder{}), `{"item_id":"i1","type":"relative","minute_offset":0}`},
+		{api.updateReminder(Reminder{Id: PTR("r1"), ItemId: PTR("i1")}, tasks.Reminder{MinutesBefore: 10}), `{"id":"r1","type":"relative","minute_offset":10}`},
+		{api.updateReminder(Reminder{Id: PTR("r2")}, abs), `{"id":"r2","type":"absolute","due":{"date":"2016-07-14T00:00:00Z","timezone":"UTC","is_recurring":false}}`},
+	}
+
+	for _, c := range cases {
+		got, err := json.Marshal(c.cmd.Args)
+		if err != nil {
+			t.Errorf("json.Marshal(%v) == error (%v), want no error", c.cmd, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("%s args == %s, want %s", *c.cmd.Type, got, c.want)
+		}
+	}
+}
+
+func TestTaskDue(t *testing.T) {
+	due := time.Date(2016, 07, 14, 20, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in   tasks.Task
+		want Due
+	}{
+		{tasks.Task{DueDateUTC: due}, Due{Date: "2016-07-14T20:00:00Z", Timezone: PTR("UTC")}},
+		{
+			tasks.Task{Recurrence: "every day at 09:00 starting 2016-07-14 until 2016-07-20", RecurrenceZone: "Europe/Lisbon"},
+			Due{String: "every day at 09:00 starting 2016-07-14 until 2016-07-20", Timezone: PTR("Europe/Lisbon"), IsRecurring: true, Language: "en"},
+		},
+	}
+
+	for _, c := range cases {
+		if got := taskDue(c.in); !reflect.DeepEqual(*got, c.want) {
+			t.Errorf("taskDue(%v) == %v, want %v", c.in, *got, c.want)
+		}
+	}
+}
+
+func TestLoadProjectRecurrence(t *testing.T) {
+	api := NewUnifiedAPI(nil)
+	api.store = newStore()
+	api.stale = false
+	api.SetLinks(map[string]string{"t1": "p1"})
+
+	// Todoist writes the recurrence back in its own words.
+	api.store.Projects["p1"] = Project{Id: PTR("p1"), Name: PTR("Trip: Lisbon")}
+	api.store.Items["i1"] = Item{
+		Id: PTR("i1"), ProjectId: PTR("p1"), Content: PTR("Water plants"),
+		Checked: boolPtr(false), ChildOrder: intPtr(1),
+		Due: &Due{String: "Every day at 9am starting 2016-07-14 until 2016-07-20", Timezone: PTR("Europe/Lisbon"), IsRecurring: true, Language: "en"},
+	}
+
+	got, found, err := api.LoadProject(context.Background(), "t1", "Trip: Lisbon")
+	if err != nil || !found {
+		t.Fatalf("LoadProject() == %v, %v, want found", found, err)
+	}
+	want := tasks.Project{Id: "t1", Name: "Trip: Lisbon", Tasks: []tasks.Task{{
+		Content:        "Water plants",
+		Indent:         1,
+		Recurrence:     "every day at 09:00 starting 2016-07-14 until 2016-07-20",
+		RecurrenceZone: "Europe/Lisbon",
+	}}}
+	if d := got.DiffTasks(want); len(d) > 0 {
+		t.Errorf("LoadProject() diffs == %v, want none", d)
+	}
+}
+
+func TestProjectName(t *testing.T) {
+	api := NewUnifiedAPI(nil)
+	api.store = newStore()
+	api.stale = false
+	api.SetLinks(map[string]string{"t1": "p1"})
+	api.store.Projects["p1"] = Project{Id: PTR("p1"), Name: PTR("Lisbon with Ana")}
+
+	p, found, err := api.LoadProject(context.Background(), "t1", "Trip: Lisbon")
+	if err != nil || !found {
+		t.Fatalf("LoadProject() == %v, %v, want found", found, err)
+	}
+	if got, want := ProjectName(p), "Lisbon with Ana"; got != want {
+		t.Errorf("ProjectName(linked) == %q, want %q", got, want)
+	}
+	if got, want := ProjectName(tasks.Project{Name: "Trip: Lisbon"}), "Trip: Lisbon"; got != want {
+		t.Errorf("ProjectName(unloaded) == %q, want %q", got, want)
+	}
+}
+
+func TestUpdateProjectParents(t *testing.T) {
+	f := &fakeSync{fail: map[string]bool{}}
+	api := newFakeClient(t, f)
+	api.store = newStore()
+	api.stale = false
+	api.SetLinks(map[string]string{"t1": "p1"})
+
+	// Pack and Passport were added on an earlier run.
+	api.store.Projects["p1"] = Project{Id: PTR("p1"), Name: PTR("Trip: Lisbon")}
+	api.store.Items["i1"] = Item{Id: PTR("i1"), ProjectId: PTR("p1"), Content: PTR("Pack"), Checked: boolPtr(false), ChildOrder: intPtr(1)}
+	api.store.Items["i2"] = Item{Id: PTR("i2"), ProjectId: PTR("p1"), ParentId: PTR("i1"), Content: PTR("Passport"), Checked: boolPtr(false), ChildOrder: intPtr(2)}
+
+	ctx := context.Background()
+	rp, found, err := api.LoadProject(ctx, "t1", "Trip: Lisbon")
+	if err != nil || !found {
+		t.Fatalf("LoadProject() == %v, %v, want found", found, err)
+	}
+	want := tasks.Project{Id: "t1", Name: "Trip: Lisbon", Tasks: []tasks.Task{
+		{Content: "Pack", Indent: 1, Position: 0},
+		{Content: "Passport", Indent: 2, Position: 1},
+		{Content: "Tickets", Indent: 2, Position: 2},
+		{Content: "Unpack", Indent: 1, Position: 3},
+		{Content: "Laundry", Indent: 2, Position: 4},
+	}}
+	if err := api.UpdateProject(ctx, rp, rp.DiffTasks(want)); err != nil {
+		t.Fatalf("UpdateProject() == %v, want no error", err)
+	}
+
+	temps := map[string]string{}
+	got := map[string]interface{}{}
+	for _, r := range f.requests {
+		for _, c := range r {
+			if *c.Type != ItemAdd {
+				continue
+			}
+			args := c.Args.(map[string]interface{})
+			content := args["content"].(string)
+			temps[*c.TempId] = content
+			got[content] = args["parent_id"]
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("UpdateProject() added %v, want Tickets, Unpack and Laundry", got)
+	}
+	if got["Tickets"] != "i1" {
+		t.Errorf("UpdateProject() Tickets parent == %v, want i1", got["Tickets"])
+	}
+	if got["Unpack"] != nil {
+		t.Errorf("UpdateProject() Unpack parent == %v, want none", got["Unpack"])
+	}
+	if p, _ := got["Laundry"].(string); temps[p] != "Unpack" {
+		t.Errorf("UpdateProject() Laundry parent == %v, want Unpack", got["Laundry"])
+	}
+}
+
+func TestLookupProject(t *testing.T) {
+	ps := []Project{
+		{Id: PTR("p1"), Name: PTR("Trip: Lisbon")},
+		{Id: PTR("p2"), Name: PTR("Lisbon 12-18 Mar")},
+		{Id: PTR("p3"), Name: PTR("Trip: Paris")},
+		{Id: PTR("p4"), Name: PTR("Trip: Porto")},
+	}
+	notes := []ProjectNote{
+		{ProjectId: PTR("p3"), Content: PTR("tripist-link: T3")},
+		{ProjectId: PTR("p4"), Content: PTR("an unrelated comment")},
+	}
+
+	cases := []struct {
+		links     map[string]string
+		id, name  string
+		want      string
+		wantLinks map[string]string
+	}{{
+		// Linked projects are found regardless of name.
+		links:     map[string]string{"T1": "p2"},
+		id:        "T1",
+		name:      "Trip: Lisbon",
+		want:      "p2",
+		wantLinks: map[string]string{"T1": "p2"},
+	}, {
+		// Unlinked projects are found by name and linked.
+		links:     map[string]string{},
+		id:        "T1",
+		name:      "Trip: Lisbon",
+		want:      "p1",
+		wantLinks: map[string]string{"T1": "p1"},
+	}, {
+		// Stale links are dropped.
+		links:     map[string]string{"T1": "p9"},
+		id:        "T1",
+		name:      "Trip: Oslo",
+		wantLinks: map[string]string{},
+	}, {
+		// Links are recovered from notes, regardless of name.
+		links:     map[string]string{},
+		id:        "T3",
+		name:      "Paris, again",
+		want:      "p3",
+		wantLinks: map[string]string{"T3": "p3"},
+	}, {
+		// Projects linked to another trip by note are not matched by name.
+		links:     map[string]string{},
+		id:        "T4",
+		name:      "Trip: Paris",
+		wantLinks: map[string]string{},
+	}, {
+		// Projects linked to another trip in the table are not matched by name.
+		links:     map[string]string{"T1": "p4"},
+		id:        "T4",
+		name:      "Trip: Porto",
+		wantLinks: map[string]string{"T1": "p4"},
+	}}
+
+	for _, c := range cases {
+		api := NewSyncV9API(nil)
+		api.SetLinks(c.links)
+
+		got := ""
+		if p := api.lookupProject(context.Background(), ps, notes, c.id, c.name); p != nil {
+			got = *p.Id
+		}
+		if got != c.want {
+			t.Errorf("lookupProject(%q, %q) == %q, want %q", c.id, c.name, got, c.want)
+		}
+		if !reflect.DeepEqual(c.links, c.wantLinks) {
+			t.Errorf("lookupProject(%q, %q) links == %v, want %v", c.id, c.name, c.links, c.wantLinks)
+		}
+	}
+}
+
+func TestCreateProjectWithOptions(t *testing.T) {
+	ps := []Project{{Id: PTR("p1"), Name: PTR("Travel")}}
+
+	api := NewSyncV9API(nil)
+	api.SetProjectOptions(ProjectOptions{Parent: "Travel", Color: "blue"})
+	cmds := api.createProjectWithOptions(context.Background(), ps, "Trip: Lisbon", "tmp")
+	if len(cmds) != 1 {
+		t.Fatalf("createProjectWithOptions() == %v, want 1 command", cmds)
+	}
+	if p := cmds[0].Args.(Project); *p.ParentId != "p1" || *p.Color != "blue" {
+		t.Errorf("createProjectWithOptions() == %v, want parent p1 and color blue", p)
+	}
+
+	// Missing parents are created first.
+	api.SetProjectOptions(ProjectOptions{Parent: "Holidays"})
+	cmds = api.createProjectWithOptions(context.Background(), ps, "Trip: Lisbon", "tmp")
+	if len(cmds) != 2 {
+		t.Fatalf("createProjectWithOptions() == %v, want 2 commands", cmds)
+	}
+	parent, child := cmds[0].Args.(Project), cmds[1].Args.(Project)
+	if *parent.Name != "Holidays" || *child.ParentId != *cmds[0].TempId || child.Color != nil {
+		t.Errorf("createProjectWithOptions() == %v, want Holidays parent created", cmds)
+	}
+}
+
+func TestConcurrentProjects(t *testing.T) {
+	f := &fakeSync{fail: map[string]bool{}}
+	api := newFakeClient(t, f)
+	links := make(map[string]string)
+	api.SetLinks(links)
+
+	const n = 8
+	ctx := context.Background()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("trip-%d", i)
+			if _, _, err := api.LoadProject(ctx, id, "Trip: "+id); err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = api.CreateProject(ctx, tasks.Project{
+				Id:    id,
+				Name:  "Trip: " + id,
+				Tasks: []tasks.Task{{Content: "Pack", Indent: 1}},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("CreateProject(trip-%d) == error (%v), want no error", i, err)
+		}
+	}
+	if len(links) != n {
+		t.Errorf("CreateProject() links == %v, want %d", links, n)
+	}
+	// Each project is written in one request, not interleaved with others.
+	for _, r := range f.requests {
+		if len(r) != 3 || *r[0].Type != "project_add" {
+			t.Errorf("CreateProject() wrote %v, want one project with its note and task", r)
+		}
+	}
+}
+
+func TestConcurrentProjectsParent(t *testing.T) {
+	f := &fakeSync{fail: map[string]bool{}}
+	api := newFakeClient(t, f)
+	api.SetProjectOptions(ProjectOptions{Parent: "Travel"})
+
+	const n = 8
+	ctx := context.Background()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("trip-%d", i)
+			errs[i] = api.CreateProject(ctx, tasks.Project{Id: id, Name: "Trip: " + id})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("CreateProject(trip-%d) == error (%v), want no error", i, err)
+		}
+	}
+	parents := 0
+	for _, p := range f.projects {
+		if *p.Name == "Travel" {
+			parents++
+		}
+	}
+	if parents != 1 || len(f.projects) != n+1 {
+		t.Errorf("CreateProject() created %v, want one Travel parent and %d projects", f.projects, n)
+	}
+}
@@ -0,0 +1,72 @@
+package todoist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
+	"golang.org/x/oauth2"
+)
+
+// TaskBackend stores projects of tasks. It is implemented for each version
+// of the Todoist API that tripist supports.
+//
+// Projects may be loaded, created, updated and deleted from several
+// goroutines at once; the Set methods must be called before that starts.
+type TaskBackend interface {
+	// LoadProject loads the project for id (see tasks.Project.Id), which
+	// should be called name. Returns the project, whether or not it was
+	// found, and any error.
+	LoadProject(ctx context.Context, id, name string) (tasks.Project, bool, error)
+
+	// CreateProject creates a project and its tasks.
+	CreateProject(ctx context.Context, p tasks.Project) error
+
+	// UpdateProject applies diffs to (and renames, if needed) a project
+	// previously returned by LoadProject.
+	UpdateProject(ctx context.Context, p tasks.Project, diffs []tasks.Diff) error
+
+	// DeleteProject deletes a project previously returned by LoadProject.
+	DeleteProject(ctx context.Context, p tasks.Project) error
+
+	// SetProjectOptions controls how projects are created.
+	SetProjectOptions(o ProjectOptions)
+
+	// SetLinks sets the table linking tasks.Project.Id to backend project
+	// IDs. The table is updated in place as projects are found and created,
+	// so the caller should persist it between runs.
+	SetLinks(l map[string]string)
+
+	// SetCacheFile keeps the backend's reads in filename between runs.
+	SetCacheFile(filename string)
+
+	// SetTimeout bounds each request to the backend.
+	SetTimeout(d time.Duration)
+
+	// SetTransport carries requests to the backend over t.
+	SetTransport(t http.RoundTripper)
+}
+
+// Names of the TaskBackend implementations, for NewTaskBackend.
+const (
+	BackendUnified = "v1"
+	BackendSyncV9  = "v9"
+)
+
+// NewTaskBackend returns the TaskBackend called name.
+func NewTaskBackend(name string, t *oauth2.Token) (TaskBackend, error) {
+	switch name {
+	case BackendUnified:
+		return NewUnifiedAPI(t), nil
+	case BackendSyncV9:
+		return NewSyncV9API(t), nil
+	}
+	return nil, fmt.Errorf("unknown Todoist API %q (want %s or %s)", name, BackendUnified, BackendSyncV9)
+}
+
+var (
+	_ TaskBackend = (*UnifiedAPI)(nil)
+	_ TaskBackend = (*SyncV9API)(nil)
+)
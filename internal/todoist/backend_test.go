@@ -0,0 +1,39 @@
+package todoist
+
+import "testing"
+
+func TestNewTaskBackend(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantPath string
+		wantErr  bool
+	}{{
+		name:     BackendUnified,
+		wantPath: UnifiedApiPath,
+	}, {
+		name:     BackendSyncV9,
+		wantPath: ApiPath,
+	}, {
+		name:    "v8",
+		wantErr: true,
+	}}
+
+	for _, c := range cases {
+		b, err := NewTaskBackend(c.name, nil)
+		if (err != nil) != c.wantErr {
+			t.Errorf("NewTaskBackend(%q) == error (%v), want error %v", c.name, err, c.wantErr)
+			continue
+		}
+
+		var got string
+		switch api := b.(type) {
+		case *UnifiedAPI:
+			got = api.path
+		case *SyncV9API:
+			got = api.path
+		}
+		if got != c.wantPath {
+			t.Errorf("NewTaskBackend(%q) uses %q, want %q", c.name, got, c.wantPath)
+		}
+	}
+}
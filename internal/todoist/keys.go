@@ -1,7 +1,8 @@
 package todoist
 
-var (
-	// https://developer.todoist.com/ to register for an oauth2 key.
-	Oauth2ClientID     = ""
-	Oauth2ClientSecret = ""
-)
+// Keys identify the application to Todoist. Register for them at
+// https://developer.todoist.com/.
+type Keys struct {
+	ClientID     string
+	ClientSecret string
+}
@@ -1,22 +1,27 @@
 package todoist
 
 import (
+	"context"
 	"fmt"
-	"log"
 
+	"github.com/seanrees/tripist/internal/loopback"
 	"golang.org/x/oauth2"
 )
 
-func buildConfig() *oauth2.Config {
+// manualRedirectURL displays the redirect's parameters so they can be
+// copied by hand (see web/params.html).
+const manualRedirectURL = "https://freyr.erifax.org/tripist/"
+
+func buildConfig(k Keys) *oauth2.Config {
 	// todoist.com requires ClientID and ClientSecret to be set as parameters
 	// in the POST.
 	//oauth2.RegisterBrokenAuthHeaderProvider("https://todoist.com")
 
 	return &oauth2.Config{
-		ClientID:     Oauth2ClientID,
-		ClientSecret: Oauth2ClientSecret,
+		ClientID:     k.ClientID,
+		ClientSecret: k.ClientSecret,
 		Scopes:       []string{"data:read_write,data:delete,project:delete"},
-		RedirectURL:  "https://freyr.erifax.org/tripist/",
+		RedirectURL:  manualRedirectURL,
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  "https://todoist.com/oauth/authorize",
 			TokenURL: "https://todoist.com/oauth/access_token",
@@ -24,22 +29,70 @@ func buildConfig() *oauth2.Config {
 	}
 }
 
-func Authorize() *oauth2.Token {
-	conf := buildConfig()
+// AuthOptions control how Authorize obtains the user's consent.
+type AuthOptions struct {
+	// Port for the local callback server; 0 picks any free port. Todoist
+	// only redirects to the URL registered for the app, so this usually
+	// needs to be fixed.
+	Port int
+
+	// Manual skips the callback server: the user copies the code from
+	// the hosted parameters page instead.
+	Manual bool
+}
+
+// Authorize runs the OAuth2 flow for the application identified by k and
+// returns the user's token. By default a local server receives Todoist's
+// redirect; if that cannot start, or opts.Manual is set, the user pastes the
+// code instead.
+func Authorize(ctx context.Context, k Keys, opts AuthOptions) (*oauth2.Token, error) {
+	conf := buildConfig(k)
+
+	var code string
+	if !opts.Manual {
+		srv, err := loopback.Listen(opts.Port)
+		if err != nil {
+			fmt.Printf("Could not start local callback server (%v), falling back to manual authorization.\n", err)
+			opts.Manual = true
+		} else {
+			defer srv.Close()
+			conf.RedirectURL = srv.URL()
 
-	// state=erifax -- totally a random string.
-	url := conf.AuthCodeURL("erifax", oauth2.AccessTypeOffline)
+			url := conf.AuthCodeURL(srv.State(), oauth2.AccessTypeOffline)
+			fmt.Println("Browse to (if your browser does not open): " + url)
+			loopback.OpenBrowser(url)
+			fmt.Println("Waiting for authorization...")
 
-	fmt.Println("1. Browse to: " + url)
-	fmt.Println("2. Grant access and copy the 'code' parameter displayed.")
-	fmt.Print("\nEnter code: ")
-	code := ""
-	fmt.Scanln(&code)
+			q, err := srv.Wait(ctx)
+			if err != nil {
+				return nil, err
+			}
+			code = q.Get("code")
+		}
+	}
+
+	if opts.Manual {
+		state, err := loopback.RandomState()
+		if err != nil {
+			return nil, err
+		}
+		url := conf.AuthCodeURL(state, oauth2.AccessTypeOffline)
+
+		fmt.Println("1. Browse to: " + url)
+		fmt.Println("2. Grant access and copy the 'code' and 'state' parameters displayed.")
+		fmt.Print("\nEnter code: ")
+		fmt.Scanln(&code)
+		got := ""
+		fmt.Print("Enter state: ")
+		fmt.Scanln(&got)
+		if got != state {
+			return nil, fmt.Errorf("state mismatch, please restart authorization")
+		}
+	}
 
-	token, err := conf.Exchange(oauth2.NoContext, code)
-	if err != nil {
-		log.Fatal(err)
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no authorization code received")
 	}
 
-	return token
+	return conf.Exchange(ctx, code)
 }
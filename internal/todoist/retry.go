@@ -0,0 +1,154 @@
+package todoist
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seanrees/tripist/internal/apierror"
+)
+
+const (
+	// MaxCommandsPerRequest is Todoist's limit on commands in one write.
+	MaxCommandsPerRequest = 100
+
+	// DefaultTimeout bounds each HTTP request to Todoist unless changed with
+	// SetTimeout.
+	DefaultTimeout = 60 * time.Second
+
+	// maxAttempts is how many times a request is tried before giving up.
+	maxAttempts = 5
+)
+
+var (
+	// retryBackoff is the wait before the first retry; it doubles for each
+	// retry after. Todoist's Retry-After takes precedence.
+	retryBackoff = 2 * time.Second
+
+	// sleep is replaced in tests.
+	sleep = sleepContext
+)
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// postWithRetry posts data to path and returns the response body. Network
+// errors, rate limiting (429) and server errors (5xx) are retried with
+// backoff, honouring Retry-After. Other non-2xx responses are returned as an
+// *apierror.HTTPError.
+func postWithRetry(ctx context.Context, c *http.Client, path string, data url.Values) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		var wait time.Duration
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, path, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := c.Do(req)
+		if ctx.Err() != nil {
+			// Cancelled or timed out; don't retry.
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			body, rerr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			switch {
+			case rerr != nil:
+				err = rerr
+			case resp.StatusCode/100 == 2:
+				return body, nil
+			default:
+				err = apierror.Check(resp, body)
+				if !retryable(resp.StatusCode) {
+					return nil, err
+				}
+				wait = retryAfter(resp.Header.Get("Retry-After"), time.Now())
+			}
+		}
+
+		if attempt >= maxAttempts {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		if wait <= 0 {
+			wait = retryBackoff << (attempt - 1)
+		}
+		slog.WarnContext(ctx, "Todoist request failed, retrying", "attempt", attempt, "max_attempts", maxAttempts, "wait", wait, "error", err)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code/100 == 5
+}
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date. Returns 0 if there is no usable value.
+func retryAfter(v string, now time.Time) time.Duration {
+	if len(v) == 0 {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// resolveTempIds returns cmds with references to temp_ids created by earlier
+// writes replaced by their real IDs, as Todoist only maps temp_ids within a
+// single request.
+func resolveTempIds(cmds Commands, mapping map[string]string) Commands {
+	resolve := func(id *string) *string {
+		if id == nil {
+			return nil
+		}
+		if real, ok := mapping[*id]; ok {
+			return &real
+		}
+		return id
+	}
+
+	ret := make(Commands, len(cmds))
+	for i, c := range cmds {
+		switch a := c.Args.(type) {
+		case Item:
+			a.ProjectId = resolve(a.ProjectId)
+			a.ParentId = resolve(a.ParentId)
+			c.Args = a
+		case Project:
+			a.ParentId = resolve(a.ParentId)
+			c.Args = a
+		case ProjectNote:
+			a.ProjectId = resolve(a.ProjectId)
+			c.Args = a
+		case Reminder:
+			a.ItemId = resolve(a.ItemId)
+			c.Args = a
+		}
+		ret[i] = c
+	}
+	return ret
+}
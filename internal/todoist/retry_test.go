@@ -0,0 +1,188 @@
+package todoist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
+)
+
+// fakeSync is a sync endpoint which fails the first len(failures) requests
+// with those status codes, then answers writes with "ok" for each command.
+// Reads return the projects created so far.
+type fakeSync struct {
+	mu       sync.Mutex
+	failures []int
+	fail     map[string]bool // command UUIDs to fail.
+	requests []Commands
+	projects []Project
+}
+
+func (f *fakeSync) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(r.FormValue("sync_token")) > 0 {
+		json.NewEncoder(w).Encode(ReadResponse{SyncToken: "token", Projects: f.projects})
+		return
+	}
+
+	var cmds Commands
+	if err := json.Unmarshal([]byte(r.FormValue("commands")), &cmds); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.requests = append(f.requests, cmds)
+
+	if len(f.failures) > 0 {
+		code := f.failures[0]
+		f.failures = f.failures[1:]
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "try later", code)
+		return
+	}
+
+	resp := WriteResponse{TempIdMapping: map[string]string{}, SyncStatus: map[string]interface{}{}}
+	for i, c := range cmds {
+		if f.fail[*c.UUID] {
+			resp.SyncStatus[*c.UUID] = map[string]interface{}{"error": "no"}
+			continue
+		}
+		resp.SyncStatus[*c.UUID] = "ok"
+		if c.TempId != nil {
+			id := fmt.Sprintf("id-%d-%d", len(f.requests), i)
+			resp.TempIdMapping[*c.TempId] = id
+			if *c.Type == ProjectAdd {
+				name := c.Args.(map[string]interface{})["name"].(string)
+				f.projects = append(f.projects, Project{Id: &id, Name: &name})
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func newFakeClient(t *testing.T, f *fakeSync) *syncClient {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	api := NewUnifiedAPI(nil)
+	api.path = srv.URL
+	api.client = srv.Client()
+	return &api.syncClient
+}
+
+func TestWriteRetries(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	defer func() { sleep = sleepContext }()
+
+	f := &fakeSync{failures: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	api := newFakeClient(t, f)
+
+	cmds := Commands{api.createProject("Trip", "tmp")}
+	if _, err := api.Write(context.Background(), cmds); err != nil {
+		t.Fatalf("Write() == error (%v), want no error", err)
+	}
+
+	if got, want := len(f.requests), 3; got != want {
+		t.Fatalf("Write() made %d requests, want %d", got, want)
+	}
+	for i, r := range f.requests {
+		if *r[0].UUID != *cmds[0].UUID || *r[0].TempId != *cmds[0].TempId {
+			t.Errorf("Write() request %d == %v, want same UUID and temp_id as %v", i, r[0], cmds[0])
+		}
+	}
+	if want := []time.Duration{time.Second, time.Second}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("Write() waited %v, want %v (from Retry-After)", waits, want)
+	}
+
+	// Client errors are not retried.
+	f.requests = nil
+	f.failures = []int{http.StatusBadRequest}
+	if _, err := api.Write(context.Background(), cmds); err == nil || len(f.requests) != 1 {
+		t.Errorf("Write() == %v after %d requests, want error after 1", err, len(f.requests))
+	}
+}
+
+func TestWriteBatches(t *testing.T) {
+	f := &fakeSync{fail: map[string]bool{}}
+	api := newFakeClient(t, f)
+
+	// Every item refers to the project created by the first command, so
+	// later batches need its real ID.
+	cmds := Commands{api.createProject("Trip", "tmp")}
+	for i := 0; i < 150; i++ {
+		cmds = append(cmds, api.createItem("tmp", nil, tasks.Task{Content: fmt.Sprintf("task %d", i), Indent: 1}))
+	}
+	f.fail[*cmds[120].UUID] = true
+
+	resp, err := api.Write(context.Background(), cmds)
+
+	var werr *WriteError
+	if !errors.As(err, &werr) {
+		t.Fatalf("Write() == error (%v), want *WriteError", err)
+	}
+	if len(werr.Failed) != 1 || werr.Total != len(cmds) || *werr.Failed[0].Item.UUID != *cmds[120].UUID {
+		t.Errorf("Write() == %v, want 1 failure of %d (uuid %s)", werr, len(cmds), *cmds[120].UUID)
+	}
+
+	if got := len(f.requests); got != 2 {
+		t.Fatalf("Write() made %d requests, want 2", got)
+	}
+	if got := len(f.requests[0]); got != MaxCommandsPerRequest {
+		t.Errorf("Write() first batch has %d commands, want %d", got, MaxCommandsPerRequest)
+	}
+
+	first := f.requests[0][1].Args.(map[string]interface{})["project_id"]
+	second := f.requests[1][0].Args.(map[string]interface{})["project_id"]
+	if first != "tmp" || second != resp.TempIdMapping["tmp"] {
+		t.Errorf("Write() project_id == %v then %v, want tmp then %v", first, second, resp.TempIdMapping["tmp"])
+	}
+}
+
+func TestWriteCancelled(t *testing.T) {
+	f := &fakeSync{failures: []int{http.StatusServiceUnavailable}}
+	api := newFakeClient(t, f)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	defer func() { sleep = sleepContext }()
+
+	_, err := api.Write(ctx, Commands{api.createProject("Trip", "tmp")})
+	if !errors.Is(err, context.Canceled) || len(f.requests) != 1 {
+		t.Errorf("Write() == %v after %d requests, want context.Canceled after 1", err, len(f.requests))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2016, 07, 15, 12, 00, 00, 00, time.UTC)
+	cases := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"Fri, 15 Jul 2016 12:01:00 GMT", time.Minute},
+		{"soon", 0},
+	}
+
+	for _, c := range cases {
+		if got := retryAfter(c.in, now); got != c.want {
+			t.Errorf("retryAfter(%q) == %v, want %v", c.in, got, c.want)
+		}
+	}
+}
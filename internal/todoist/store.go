@@ -0,0 +1,175 @@
+package todoist
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// storeTypes are the resource types kept in a store.
+var storeTypes = []string{Items, Projects, ProjectNotes, Reminders}
+
+// store is a local copy of the Todoist resources tripist uses. It is brought
+// up to date by applying the (full or incremental) results of a sync read.
+type store struct {
+	// SyncToken is the token to send for the next incremental read, or ""
+	// if a full read is needed.
+	SyncToken string
+
+	Items        map[string]Item
+	Projects     map[string]Project
+	ProjectNotes map[string]ProjectNote
+	Reminders    map[string]Reminder
+}
+
+func newStore() *store {
+	return &store{
+		Items:        make(map[string]Item),
+		Projects:     make(map[string]Project),
+		ProjectNotes: make(map[string]ProjectNote),
+		Reminders:    make(map[string]Reminder),
+	}
+}
+
+func deleted(b *bool) bool { return b != nil && *b }
+
+// apply merges a read into the store. Full reads replace the store's
+// contents; incremental reads update and delete individual resources.
+func (st *store) apply(r ReadResponse) {
+	if r.FullSync {
+		*st = *newStore()
+	}
+	st.SyncToken = r.SyncToken
+
+	for _, i := range r.Items {
+		if i.Id == nil {
+			continue
+		}
+		if deleted(i.IsDeleted) {
+			delete(st.Items, *i.Id)
+		} else {
+			st.Items[*i.Id] = i
+		}
+	}
+	for _, p := range r.Projects {
+		if p.Id == nil {
+			continue
+		}
+		if deleted(p.IsDeleted) {
+			delete(st.Projects, *p.Id)
+		} else {
+			st.Projects[*p.Id] = p
+		}
+	}
+	for _, n := range r.ProjectNotes {
+		if n.Id == nil {
+			continue
+		}
+		if deleted(n.IsDeleted) {
+			delete(st.ProjectNotes, *n.Id)
+		} else {
+			st.ProjectNotes[*n.Id] = n
+		}
+	}
+	for _, rem := range r.Reminders {
+		if rem.Id == nil {
+			continue
+		}
+		if deleted(rem.IsDeleted) {
+			delete(st.Reminders, *rem.Id)
+		} else {
+			st.Reminders[*rem.Id] = rem
+		}
+	}
+}
+
+// projectItems returns the items in a project, parents before their
+// children and siblings in child order.
+func (st *store) projectItems(projectId string) []Item {
+	children := make(map[string][]Item)
+	ids := make(map[string]bool)
+	for _, i := range st.Items {
+		if i.ProjectId != nil && *i.ProjectId == projectId {
+			ids[*i.Id] = true
+		}
+	}
+	for _, i := range st.Items {
+		if !ids[*i.Id] {
+			continue
+		}
+		parent := ""
+		if i.ParentId != nil && ids[*i.ParentId] {
+			parent = *i.ParentId
+		}
+		children[parent] = append(children[parent], i)
+	}
+
+	var ret []Item
+	var walk func(parent string)
+	walk = func(parent string) {
+		cs := children[parent]
+		sort.Slice(cs, func(a, b int) bool {
+			if oa, ob := childOrder(cs[a]), childOrder(cs[b]); oa != ob {
+				return oa < ob
+			}
+			return *cs[a].Id < *cs[b].Id
+		})
+		for _, c := range cs {
+			ret = append(ret, c)
+			walk(*c.Id)
+		}
+	}
+	walk("")
+	return ret
+}
+
+func childOrder(i Item) int {
+	if i.ChildOrder == nil {
+		return 0
+	}
+	return *i.ChildOrder
+}
+
+// readStore loads a store saved by writeStore. A missing file yields an empty
+// store, which will be filled by a full read.
+func readStore(filename string) (*store, error) {
+	st := newStore()
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return newStore(), err
+	}
+	return st, nil
+}
+
+// writeStore saves a store. It holds the user's tasks, so is only readable
+// by the user.
+func writeStore(st *store, filename string) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,98 @@
+package todoist
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func intPtr(i int) *int    { return &i }
+func boolPtr(b bool) *bool { return &b }
+
+func TestStoreApply(t *testing.T) {
+	st := newStore()
+	st.apply(ReadResponse{
+		SyncToken: "t1",
+		FullSync:  true,
+		Items: []Item{
+			{Id: PTR("i1"), Content: PTR("one")},
+			{Id: PTR("i2"), Content: PTR("two")},
+		},
+		Projects: []Project{{Id: PTR("p1"), Name: PTR("Trip")}},
+	})
+
+	// Incremental reads update, add and delete.
+	st.apply(ReadResponse{
+		SyncToken: "t2",
+		Items: []Item{
+			{Id: PTR("i1"), Content: PTR("one, updated")},
+			{Id: PTR("i2"), IsDeleted: boolPtr(true)},
+			{Id: PTR("i3"), Content: PTR("three")},
+		},
+		Reminders: []Reminder{{Id: PTR("r1"), ItemId: PTR("i1")}},
+	})
+
+	if st.SyncToken != "t2" {
+		t.Errorf("apply() SyncToken == %q, want t2", st.SyncToken)
+	}
+	var got []string
+	for _, id := range []string{"i1", "i2", "i3"} {
+		if i, ok := st.Items[id]; ok {
+			got = append(got, *i.Content)
+		}
+	}
+	if want := []string{"one, updated", "three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("apply() items == %v, want %v", got, want)
+	}
+	if len(st.Projects) != 1 || len(st.Reminders) != 1 {
+		t.Errorf("apply() == %d projects and %d reminders, want 1 and 1", len(st.Projects), len(st.Reminders))
+	}
+
+	// Full reads replace everything.
+	st.apply(ReadResponse{SyncToken: "t3", FullSync: true})
+	if len(st.Items)+len(st.Projects)+len(st.Reminders) != 0 {
+		t.Errorf("apply(full) == %v, want empty store", st)
+	}
+}
+
+func TestStoreProjectItems(t *testing.T) {
+	st := newStore()
+	st.apply(ReadResponse{Items: []Item{
+		{Id: PTR("b"), ProjectId: PTR("p1"), Content: PTR("two"), ChildOrder: intPtr(2)},
+		{Id: PTR("b1"), ProjectId: PTR("p1"), ParentId: PTR("b"), Content: PTR("two.one"), ChildOrder: intPtr(1)},
+		{Id: PTR("a2"), ProjectId: PTR("p1"), ParentId: PTR("a"), Content: PTR("one.two"), ChildOrder: intPtr(2)},
+		{Id: PTR("a1"), ProjectId: PTR("p1"), ParentId: PTR("a"), Content: PTR("one.one"), ChildOrder: intPtr(1)},
+		{Id: PTR("a"), ProjectId: PTR("p1"), Content: PTR("one"), ChildOrder: intPtr(1)},
+		{Id: PTR("x"), ProjectId: PTR("p2"), Content: PTR("other project"), ChildOrder: intPtr(1)},
+	}})
+
+	var got []string
+	for _, i := range st.projectItems("p1") {
+		got = append(got, *i.Content)
+	}
+	want := []string{"one", "one.one", "one.two", "two", "two.one"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("projectItems(p1) == %v, want %v", got, want)
+	}
+}
+
+func TestReadWriteStore(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "cache.json")
+
+	st, err := readStore(fn)
+	if err != nil {
+		t.Fatalf("readStore(missing) == error (%v), want no error", err)
+	}
+	st.apply(ReadResponse{SyncToken: "t1", Items: []Item{{Id: PTR("i1"), Content: PTR("one")}}})
+
+	if err := writeStore(st, fn); err != nil {
+		t.Fatalf("writeStore() == error (%v), want no error", err)
+	}
+	got, err := readStore(fn)
+	if err != nil {
+		t.Fatalf("readStore() == error (%v), want no error", err)
+	}
+	if !reflect.DeepEqual(got, st) {
+		t.Errorf("readStore() == %v, want %v", got, st)
+	}
+}
@@ -0,0 +1,17 @@
+package todoist
+
+import "golang.org/x/oauth2"
+
+const (
+	ApiPath = "https://todoist.com/API/v9/sync"
+)
+
+// SyncV9API is a TaskBackend for Todoist's Sync v9 API, which Todoist is
+// retiring. Prefer UnifiedAPI; this remains until v9 is switched off.
+type SyncV9API struct {
+	syncClient
+}
+
+func NewSyncV9API(t *oauth2.Token) *SyncV9API {
+	return &SyncV9API{newSyncClient(t, ApiPath, true)}
+}
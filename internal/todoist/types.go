@@ -7,15 +7,26 @@ import (
 
 const (
 	// Types that can be read.
-	Items    = "items"
-	Projects = "projects"
+	Items        = "items"
+	Projects     = "projects"
+	ProjectNotes = "project_notes"
+	Reminders    = "reminders"
 
 	// Types that can be written.
-	ItemAdd       = "item_add"
-	ItemDelete    = "item_delete"
-	ItemUpdate    = "item_update"
-	ProjectAdd    = "project_add"
-	ProjectDelete = "project_delete"
+	ItemAdd        = "item_add"
+	ItemDelete     = "item_delete"
+	ItemUpdate     = "item_update"
+	NoteAdd        = "note_add"
+	ProjectAdd     = "project_add"
+	ProjectDelete  = "project_delete"
+	ProjectUpdate  = "project_update"
+	ReminderAdd    = "reminder_add"
+	ReminderDelete = "reminder_delete"
+	ReminderUpdate = "reminder_update"
+
+	// Reminder types.
+	ReminderRelative = "relative"
+	ReminderAbsolute = "absolute"
 )
 
 // This is like the %+v verb in fmt, but dereferences pointers.
@@ -58,8 +69,17 @@ func stringify(obj interface{}) string {
 type ReadResponse struct {
 	SequenceNumber *int `json:"seq_no"`
 	UserId         *int
-	Items          []Item
-	Projects       []Project
+
+	// SyncToken to send with the next read to receive only changes.
+	SyncToken string `json:"sync_token"`
+
+	// FullSync is true if this is a full read rather than a set of changes.
+	FullSync bool `json:"full_sync"`
+
+	Items        []Item
+	Projects     []Project
+	ProjectNotes []ProjectNote `json:"project_notes"`
+	Reminders    []Reminder
 }
 
 func (i ReadResponse) String() string {
@@ -125,20 +145,20 @@ func (i Item) String() string {
 
 type Due struct {
 	// RFC3339 or YYYY-MM-DDTHH:MM:SS (no trailing Z.)
-	Date        string  `json:"date"`
+	Date        string  `json:"date,omitempty"`
 	Timezone    *string `json:"timezone"`
 	IsRecurring bool    `json:"is_recurring"`
-	String      string  `json:"string"`
-	Language    string  `json:"lang"`
+	String      string  `json:"string,omitempty"`
+	Language    string  `json:"lang,omitempty"`
 }
 
 type Project struct {
-	Id           *string `json:"id"`
-	Name         *string `json:"name"`
-	Color        *string `json:"color"`
-	ParentId     *string `json:"parent_id"`
-	ChildOrder   *int    `json:"child_order"`
-	Collapsed    *bool   `json:"collapsed"`
+	Id           *string `json:"id,omitempty"`
+	Name         *string `json:"name,omitempty"`
+	Color        *string `json:"color,omitempty"`
+	ParentId     *string `json:"parent_id,omitempty"`
+	ChildOrder   *int    `json:"child_order,omitempty"`
+	Collapsed    *bool   `json:"collapsed,omitempty"`
 	Shared       *bool   `json:"shared,*string"`
 	IsDeleted    *bool   `json:"is_deleted"`
 	IsArchived   *bool   `json:"is_archived"`
@@ -150,13 +170,63 @@ func (i Project) String() string {
 	return stringify(i)
 }
 
+// ProjectNote is a comment on a project.
+type ProjectNote struct {
+	Id        *string `json:"id,omitempty"`
+	ProjectId *string `json:"project_id"`
+	Content   *string `json:"content"`
+	IsDeleted *bool   `json:"is_deleted,omitempty"`
+}
+
+func (n ProjectNote) String() string {
+	return stringify(n)
+}
+
+type Reminder struct {
+	Id           *string `json:"id,omitempty"`
+	ItemId       *string `json:"item_id,omitempty"`
+	Type         *string `json:"type,omitempty"`
+	Due          *Due    `json:"due,omitempty"`
+	MinuteOffset *int    `json:"minute_offset,omitempty"`
+	IsDeleted    *bool   `json:"is_deleted,omitempty"`
+}
+
+func (r Reminder) String() string {
+	return stringify(r)
+}
+
 // For riding along when interfacing with the local tasks API.
 type projectItems struct {
 	ProjectId string
-	Items     []Item
+
+	// Name of the project in Todoist.
+	Name string
+
+	// Linked is true if the project carries a link note for its
+	// tasks.Project.Id.
+	Linked bool
+
+	Items []Item
+
+	// Reminders keyed by item ID.
+	Reminders map[string][]Reminder
 }
 
-type writeError struct {
+// CommandError describes a command that Todoist did not apply.
+type CommandError struct {
 	Message string
 	Item    WriteItem
 }
+
+// WriteError is returned by Write when some of its commands fail.
+type WriteError struct {
+	// Failed commands, each with the reason it failed.
+	Failed []CommandError
+
+	// Total number of commands written.
+	Total int
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("write failed for %d/%d commands", len(e.Failed), e.Total)
+}
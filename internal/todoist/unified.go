@@ -0,0 +1,17 @@
+package todoist
+
+import "golang.org/x/oauth2"
+
+const (
+	UnifiedApiPath = "https://api.todoist.com/api/v1/sync"
+)
+
+// UnifiedAPI is a TaskBackend for Todoist's unified API (v1). It keeps the
+// Sync v9 command protocol but authenticates only with a bearer token.
+type UnifiedAPI struct {
+	syncClient
+}
+
+func NewUnifiedAPI(t *oauth2.Token) *UnifiedAPI {
+	return &UnifiedAPI{newSyncClient(t, UnifiedApiPath, false)}
+}
@@ -1,8 +1,9 @@
 package todoist
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
 	"time"
 
@@ -24,32 +25,21 @@ func init() {
 //
 // This could be rewritten with Go's testing package but would require a TestMain to setup
 // the API and user keys.
-func Verify(api *SyncV9API) error {
+func Verify(ctx context.Context, api TaskBackend) error {
 	step := 0
 
 	var err error
-	var p *Project
 	var tp *tasks.Project
-	var items []Item
 
-	name := randomProjectName()
-	if _, err = verifyProjectPresence(name, &step, false, api); err != nil {
-		return err
-	}
+	// Keep our links out of the caller's.
+	api.SetLinks(make(map[string]string))
 
-	l(&step, "Creating project %q", name)
-	err = api.CreateProject(tasks.Project{Name: name})
-	if err != nil {
+	id, name := randomProjectName()
+	if _, err = verifyProjectPresence(ctx, id, name, &step, false, api); err != nil {
 		return err
 	}
 
-	l(&step, "Verifying %q created successfully", name)
-	p, err = verifyProjectPresence(name, &step, true, api)
-	if err != nil {
-		return err
-	}
-
-	l(&step, "Adding items to project %q", name)
+	l(&step, "Creating project %q with items", name)
 	due := time.Date(2016, 07, 15, 12, 00, 00, 00, time.UTC)
 	testTasks := []tasks.Task{
 		{Content: "one", Indent: 1, Position: 1, DueDateUTC: due},
@@ -58,66 +48,55 @@ func Verify(api *SyncV9API) error {
 		{Content: "two", Indent: 1, Position: 2, DueDateUTC: due},
 		{Content: "two.one", Indent: 2, Position: 1, DueDateUTC: due},
 	}
-	cmds := Commands{}
-	id := *p.Id
-
-	parents := make([]*string, 3)
-
-	for _, t := range testTasks {
-		i := api.createItem(id, parents[t.Indent-1], t)
-		parents[t.Indent] = i.TempId
-		cmds = append(cmds, i)
+	err = api.CreateProject(ctx, tasks.Project{Id: id, Name: name, Tasks: testTasks})
+	if err != nil {
+		return err
 	}
-	if _, err := api.Write(cmds); err != nil {
+
+	l(&step, "Verifying %q created successfully", name)
+	if _, err = verifyProjectPresence(ctx, id, name, &step, true, api); err != nil {
 		return err
 	}
 
-	tp, err = verifyTasksInProject(name, &step, testTasks, api)
+	tp, err = verifyTasksInProject(ctx, id, name, &step, testTasks, api)
 	if err != nil {
 		return fmt.Errorf("unable to load tasks in %q: %v", name, err)
 	}
 
 	l(&step, "Updating an item in project %q", name)
 	testTasks[0].Position = 3
-	testTasks[0].DueDateUTC.Add(24 * time.Hour)
+	testTasks[0].DueDateUTC = testTasks[0].DueDateUTC.Add(24 * time.Hour)
 	d := tasks.Diff{Type: tasks.Changed, Task: testTasks[0]}
-	err = api.UpdateProject(*tp, []tasks.Diff{d})
+	err = api.UpdateProject(ctx, *tp, []tasks.Diff{d})
 	if err != nil {
 		return err
 	}
 
-	_, err = verifyTasksInProject(name, &step, testTasks, api)
-	if err == nil {
-		// We changed an item by bumping it 24hrs -- so we should see a change
-		return fmt.Errorf("expected change in tasks, did not")
-	}
-
-	l(&step, "Deleting an item")
-	items, err = api.listItems(p)
-	del := items[0]
+	tp, err = verifyTasksInProject(ctx, id, name, &step, testTasks, api)
 	if err != nil {
-		return err
+		return fmt.Errorf("update not applied in %q: %v", name, err)
 	}
-	if _, err = api.Write(Commands{api.deleteItem(del)}); err != nil {
+
+	l(&step, "Renaming project %q", name)
+	renamed := name + " renamed"
+	tp.Name = renamed
+	if err = api.UpdateProject(ctx, *tp, nil); err != nil {
 		return err
 	}
-	items, err = api.listItems(p)
-	if err != nil {
+	// Linked projects are found by id, whatever name we ask for.
+	if tp, err = verifyProjectPresence(ctx, id, name, &step, true, api); err != nil {
 		return err
 	}
-
-	for _, i := range items {
-		if *i.Content == *del.Content {
-			return fmt.Errorf("delete item failed on item %q", *i.Content)
-		}
+	if got := tp.External.(*projectItems).Name; got != renamed {
+		return fmt.Errorf("rename failed, project is called %q", got)
 	}
 
-	l(&step, "Deleting project %q", name)
-	if _, err = api.Write(Commands{api.deleteProject(p)}); err != nil {
+	l(&step, "Deleting project %q", renamed)
+	if err = api.DeleteProject(ctx, *tp); err != nil {
 		return err
 	}
 
-	if _, err = verifyProjectPresence(name, &step, false, api); err != nil {
+	if _, err = verifyProjectPresence(ctx, id, renamed, &step, false, api); err != nil {
 		return err
 	}
 
@@ -125,28 +104,27 @@ func Verify(api *SyncV9API) error {
 }
 
 func l(step *int, s string, v ...interface{}) {
-	msg := fmt.Sprintf("[step %02d] %s", *step, s)
-	log.Printf(msg, v...)
+	slog.Info(fmt.Sprintf(s, v...), "step", *step)
 	*step++
 }
 
-func randomProjectName() string {
+// randomProjectName returns an id and name for a verification project.
+func randomProjectName() (string, string) {
 	chars := []rune("abcdefABCDEF0123456789")
 	name := make([]rune, 5)
 	for i := range name {
 		name[i] = chars[rand.Intn(len(chars))]
 	}
-	return fmt.Sprintf("Todoist Verification (%s)", string(name))
+	return "verify-" + string(name), fmt.Sprintf("Todoist Verification (%s)", string(name))
 }
 
-func verifyProjectPresence(name string, step *int, expected bool, api *SyncV9API) (*Project, error) {
+func verifyProjectPresence(ctx context.Context, id, name string, step *int, expected bool, api TaskBackend) (*tasks.Project, error) {
 	l(step, "Checking %q presence", name)
 
-	p, err := api.findProject(name)
+	p, found, err := api.LoadProject(ctx, id, name)
 	if err != nil {
 		return nil, err
 	}
-	found := p != nil
 	if found != expected {
 		if found {
 			return nil, fmt.Errorf("%q found which should NOT exist", name)
@@ -155,12 +133,12 @@ func verifyProjectPresence(name string, step *int, expected bool, api *SyncV9API
 		}
 	}
 
-	return p, nil
+	return &p, nil
 }
 
-func verifyTasksInProject(name string, step *int, expected []tasks.Task, api *SyncV9API) (*tasks.Project, error) {
+func verifyTasksInProject(ctx context.Context, id, name string, step *int, expected []tasks.Task, api TaskBackend) (*tasks.Project, error) {
 	l(step, "Verifying items in project %q", name)
-	tp, found, err := api.LoadProject(name)
+	tp, found, err := api.LoadProject(ctx, id, name)
 	if err != nil {
 		return nil, err
 	}
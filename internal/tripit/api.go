@@ -1,45 +1,76 @@
 package tripit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/mrjones/oauth"
+	"github.com/seanrees/tripist/internal/apierror"
+	"github.com/seanrees/tripist/internal/logging"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
+	"sort"
 	"time"
 )
 
 const (
 	ApiPath = "https://api.tripit.com/v1"
+
+	// DefaultTimeout bounds each HTTP request to TripIt unless changed with
+	// SetTimeout.
+	DefaultTimeout = 60 * time.Second
 )
 
 type TripitV1API struct {
+	keys        Keys
 	accessToken *oauth.AccessToken
+	timeout     time.Duration
+	transport   http.RoundTripper
+}
+
+// NewTripitV1API returns a client for the application identified by k,
+// acting for the user who granted at.
+func NewTripitV1API(k Keys, at *oauth.AccessToken) *TripitV1API {
+	return &TripitV1API{keys: k, accessToken: at, timeout: DefaultTimeout}
+}
+
+// SetTimeout bounds each HTTP request to TripIt.
+func (t *TripitV1API) SetTimeout(d time.Duration) {
+	t.timeout = d
 }
 
-func NewTripitV1API(at *oauth.AccessToken) *TripitV1API {
-	return &TripitV1API{accessToken: at}
+// SetTransport carries requests to TripIt over rt, e.g; to instrument them.
+func (t *TripitV1API) SetTransport(rt http.RoundTripper) {
+	t.transport = rt
 }
 
 func (t *TripitV1API) makeClient() (*http.Client, error) {
-	c, err := buildConsumer().MakeHttpClient(t.accessToken)
+	consumer := buildConsumer(t.keys)
+	consumer.HttpClient = &http.Client{Transport: logging.Transport("tripit", t.transport)}
+	c, err := consumer.MakeHttpClient(t.accessToken)
 	if err != nil {
 		return nil, err
 	}
+	c.Timeout = t.timeout
 
 	return c, nil
 }
 
 type callbackFn func([]byte) error
 
-func (t *TripitV1API) makeRequest(url string, cb callbackFn) error {
+func (t *TripitV1API) makeRequest(ctx context.Context, url string, cb callbackFn) error {
 	c, err := t.makeClient()
 	if err != nil {
 		return err
 	}
 
-	resp, err := c.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Do(req)
 	if err != nil {
 		return err
 	}
@@ -50,6 +81,11 @@ func (t *TripitV1API) makeRequest(url string, cb callbackFn) error {
 		return err
 	}
 
+	// Don't try to decode error pages.
+	if err := apierror.Check(resp, data); err != nil {
+		return err
+	}
+
 	return cb(data)
 }
 
@@ -62,7 +98,7 @@ type ListParameters struct {
 }
 
 // Lists trips.
-func (t *TripitV1API) ListRaw(p *ListParameters) (*TripitResponse, error) {
+func (t *TripitV1API) ListRaw(ctx context.Context, p *ListParameters) (*TripitResponse, error) {
 	path := ApiPath + "/list/trip"
 
 	// TripIt has 1-indexed pages. Sigh. Why?!
@@ -80,42 +116,10 @@ func (t *TripitV1API) ListRaw(p *ListParameters) (*TripitResponse, error) {
 
 	tr := TripitResponse{}
 	cb := func(data []byte) error {
-		err := json.Unmarshal(data, &tr)
-		if err != nil {
-			// Workaround some broken Tripit behaviour: if there is only one
-			// trip, we'll not be able to parse the JSON as a list-of-Trips. So
-			// we parse out just the single Trip with a special message and then
-			// copy it in.
-			if len(tr.Trip) == 0 {
-				log.Printf("No trips loaded and unmarshal error, trying single trip variant")
-				sr := TripitSingleTripResponse{}
-				if err := json.Unmarshal(data, &sr); err == nil {
-					log.Printf("Loaded trip via single-trip variant")
-					tr.Trip = append(tr.Trip, sr.Trip)
-					return nil
-				} else {
-					log.Printf("Single-trip variant failed with: %v", err)
-				}
-			}
-			if len(tr.AirObject) == 0 {
-				log.Printf("No AirObjects loaded and unmarshal error, trying single-AO variant")
-				sr := TripitSingleAirObjectResponse{}
-				if err := json.Unmarshal(data, &sr); err == nil {
-					log.Printf("Loaded AirObject via single-AO variant")
-					tr.AirObject = append(tr.AirObject, sr.AirObject)
-				} else {
-					log.Printf("Single-AO variant failed with: %v", err)
-				}
-
-				// We might legitimately have no AirObjects, so this is non-fatal.
-				return nil
-			}
-			return err
-		}
-		return nil
+		return unmarshalResponse(data, &tr)
 	}
 
-	err := t.makeRequest(path, cb)
+	err := t.makeRequest(ctx, path, cb)
 	if err != nil {
 		return nil, err
 	}
@@ -124,12 +128,13 @@ func (t *TripitV1API) ListRaw(p *ListParameters) (*TripitResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	attachLodging(&tr)
 
 	return &tr, nil
 }
 
-func (t *TripitV1API) List(p *ListParameters) ([]Trip, error) {
-	if tr, err := t.ListRaw(p); err != nil {
+func (t *TripitV1API) List(ctx context.Context, p *ListParameters) ([]Trip, error) {
+	if tr, err := t.ListRaw(ctx, p); err != nil {
 		return nil, err
 	} else {
 		return tr.Trip, err
@@ -142,10 +147,12 @@ func fixStartAndEndDates(tr *TripitResponse) error {
 		t := &tr.Trip[i]
 
 		var min, max time.Time
+		var flights []flight
 		for _, a := range tr.AirObject {
 			if a.TripId == t.Id {
 				for _, s := range a.Segments() {
-					for _, d := range []DateTime{s.StartDateTime, s.EndDateTime} {
+					f := flight{Segment: s}
+					for n, d := range []DateTime{s.StartDateTime, s.EndDateTime} {
 						ti, err := d.Parse()
 						if err != nil {
 							return err
@@ -156,10 +163,17 @@ func fixStartAndEndDates(tr *TripitResponse) error {
 						if min.IsZero() || ti.Before(min) {
 							min = ti
 						}
+						if n == 0 {
+							f.departs = ti
+						} else {
+							f.arrives = ti
+						}
 					}
+					flights = append(flights, f)
 				}
 			}
 		}
+		t.DestinationTimezone = destination(flights)
 
 		var err error
 		if min.IsZero() {
@@ -167,8 +181,10 @@ func fixStartAndEndDates(tr *TripitResponse) error {
 			if err != nil {
 				return err
 			}
+			t.StartSource = FromTrip
 		} else {
 			t.ActualStartDate = min
+			t.StartSource = FromFlights
 		}
 
 		if max.IsZero() {
@@ -176,10 +192,128 @@ func fixStartAndEndDates(tr *TripitResponse) error {
 			if err != nil {
 				return err
 			}
+			t.EndSource = FromTrip
 		} else {
 			t.ActualEndDate = max
+			t.EndSource = FromFlights
 		}
 	}
 
 	return nil
 }
+
+// A flight is a Segment with its departure and arrival parsed.
+type flight struct {
+	Segment
+	departs, arrives time.Time
+}
+
+// maxConnection is the longest wait between flights for them to be one
+// journey; IATA counts a longer wait as a stopover.
+const maxConnection = 24 * time.Hour
+
+// destination returns the timezone where the outbound journey lands: the
+// first flight, and any connections from it. A flight connects if it departs
+// within maxConnection of the one before landing, from the same airport
+// (where both are known), and does not fly back to where the journey began,
+// as on a day trip. It returns "" if there are no flights.
+func destination(fs []flight) string {
+	if len(fs) == 0 {
+		return ""
+	}
+	sort.SliceStable(fs, func(a, b int) bool { return fs[a].departs.Before(fs[b].departs) })
+
+	last := fs[0]
+	for _, f := range fs[1:] {
+		if f.departs.Sub(last.arrives) > maxConnection {
+			break
+		}
+		if len(f.StartAirportCode) > 0 && len(last.EndAirportCode) > 0 && f.StartAirportCode != last.EndAirportCode {
+			break
+		}
+		if len(f.EndAirportCode) > 0 && f.EndAirportCode == fs[0].StartAirportCode {
+			break
+		}
+		last = f
+	}
+	return last.EndDateTime.Timezone
+}
+
+// attachLodging gives each trip its lodging, in order of check-in (see
+// SortLodging).
+func attachLodging(tr *TripitResponse) {
+	for i := range tr.Trip {
+		t := &tr.Trip[i]
+		t.Lodging = nil
+		for _, l := range tr.LodgingObject {
+			if l.TripId == t.Id {
+				t.Lodging = append(t.Lodging, l)
+			}
+		}
+		SortLodging(t.Lodging)
+	}
+}
+
+// SortLodging puts ls in order of check-in. Lodging whose check-in cannot be
+// parsed is put last.
+func SortLodging(ls []LodgingObject) {
+	sort.SliceStable(ls, func(a, b int) bool {
+		ta, erra := ls[a].CheckIn()
+		tb, errb := ls[b].CheckIn()
+		if erra != nil || errb != nil {
+			return erra == nil
+		}
+		return ta.Before(tb)
+	})
+}
+
+// ParseResponse parses a response to a list request, e.g; one saved to a file,
+// and corrects its trips' dates as List does.
+func ParseResponse(data []byte) (*TripitResponse, error) {
+	tr := TripitResponse{}
+	if err := unmarshalResponse(data, &tr); err != nil {
+		return nil, err
+	}
+	if err := fixStartAndEndDates(&tr); err != nil {
+		return nil, err
+	}
+	attachLodging(&tr)
+	return &tr, nil
+}
+
+// unmarshalResponse parses a response to a list request into tr.
+func unmarshalResponse(data []byte, tr *TripitResponse) error {
+	err := json.Unmarshal(data, tr)
+	if err != nil {
+		// Workaround some broken Tripit behaviour: if there is only one
+		// trip, we'll not be able to parse the JSON as a list-of-Trips. So
+		// we parse out just the single Trip with a special message and then
+		// copy it in.
+		if len(tr.Trip) == 0 {
+			slog.Debug("No trips loaded and unmarshal error, trying single trip variant")
+			sr := TripitSingleTripResponse{}
+			if err := json.Unmarshal(data, &sr); err == nil {
+				slog.Debug("Loaded trip via single-trip variant")
+				tr.Trip = append(tr.Trip, sr.Trip)
+				return nil
+			} else {
+				slog.Warn("Single-trip variant failed", "error", err)
+			}
+		}
+		if len(tr.AirObject) == 0 {
+			slog.Debug("No AirObjects loaded and unmarshal error, trying single-AO variant")
+			sr := TripitSingleAirObjectResponse{}
+			if err := json.Unmarshal(data, &sr); err == nil {
+				slog.Debug("Loaded AirObject via single-AO variant")
+				tr.AirObject = append(tr.AirObject, sr.AirObject)
+			} else {
+				slog.Warn("Single-AO variant failed", "error", err)
+			}
+
+			// We might legitimately have no AirObjects, so this is non-fatal.
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package tripit
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -18,11 +19,19 @@ func makeDateTime(d, t, tz, utc string) map[string]interface{} {
 	return map[string]interface{}{"date": d, "time": t, "timezone": tz, "utc_offset": utc}
 }
 
+// makeFlight makes a segment from one airport to another.
+func makeFlight(from, to string, sd, ed map[string]interface{}) map[string]interface{} {
+	s := makeSegment(sd, ed)
+	s["start_airport_code"], s["end_airport_code"] = from, to
+	return s
+}
+
 func TestFixStartAndEndDates(t *testing.T) {
 	trips := []Trip{
 		{Id: "T0", DisplayName: "Trip 0", StartDate: "2016-08-16", EndDate: "2016-08-18"},
 		{Id: "T1", DisplayName: "Trip 1", StartDate: "2016-08-16", EndDate: "2016-08-16"},
 		{Id: "T2", DisplayName: "Trip 2", StartDate: "2016-08-17", EndDate: "2016-08-20"},
+		{Id: "T3", DisplayName: "Trip 3", StartDate: "2016-08-20", EndDate: "2016-08-25"},
 	}
 
 	airObjects := []AirObject{{
@@ -48,6 +57,24 @@ func TestFixStartAndEndDates(t *testing.T) {
 		Segment: makeSegment(
 			makeDateTime("2016-08-16", "06:15:00", "Europe/Dublin", "+01:00"),
 			makeDateTime("2016-08-16", "09:30:00", "Europe/Dublin", "+01:00")),
+	}, {
+		// Out to Lisbon through London, and back direct.
+		Id:     "A3",
+		TripId: "T3",
+		Segment: []interface{}{
+			makeFlight("DUB", "LHR",
+				makeDateTime("2016-08-20", "07:00:00", "Europe/Dublin", "+01:00"),
+				makeDateTime("2016-08-20", "08:20:00", "Europe/London", "+01:00")),
+			makeFlight("LHR", "LIS",
+				makeDateTime("2016-08-20", "11:00:00", "Europe/London", "+01:00"),
+				makeDateTime("2016-08-20", "13:40:00", "Europe/Lisbon", "+01:00")),
+		},
+	}, {
+		Id:     "A4",
+		TripId: "T3",
+		Segment: makeFlight("LIS", "DUB",
+			makeDateTime("2016-08-25", "18:00:00", "Europe/Lisbon", "+01:00"),
+			makeDateTime("2016-08-25", "20:30:00", "Europe/Dublin", "+01:00")),
 	}}
 
 	loc, err := time.LoadLocation("Europe/Dublin")
@@ -65,14 +92,32 @@ func TestFixStartAndEndDates(t *testing.T) {
 		Id:              "T0",
 		ActualStartDate: time.Date(2016, 8, 16, 10, 00, 00, 00, loc),
 		ActualEndDate:   time.Date(2016, 8, 18, 20, 30, 00, 00, loc),
+		StartSource:     FromFlights,
+		EndSource:       FromFlights,
+
+		DestinationTimezone: "Europe/Dublin",
 	}, {
 		Id:              "T1",
 		ActualStartDate: time.Date(2016, 8, 16, 6, 15, 00, 00, loc),
 		ActualEndDate:   time.Date(2016, 8, 16, 9, 30, 00, 00, loc),
+		StartSource:     FromFlights,
+		EndSource:       FromFlights,
+
+		DestinationTimezone: "Europe/Dublin",
 	}, {
 		Id:              "T2",
 		ActualStartDate: time.Date(2016, 8, 17, 00, 00, 00, 00, time.UTC),
 		ActualEndDate:   time.Date(2016, 8, 20, 00, 00, 00, 00, time.UTC),
+		StartSource:     FromTrip,
+		EndSource:       FromTrip,
+	}, {
+		Id:              "T3",
+		ActualStartDate: time.Date(2016, 8, 20, 7, 00, 00, 00, loc),
+		ActualEndDate:   time.Date(2016, 8, 25, 20, 30, 00, 00, loc),
+		StartSource:     FromFlights,
+		EndSource:       FromFlights,
+
+		DestinationTimezone: "Europe/Lisbon",
 	}}
 
 	for _, tr := range resp.Trip {
@@ -84,7 +129,155 @@ func TestFixStartAndEndDates(t *testing.T) {
 				if g, w := tr.ActualEndDate, wa.ActualEndDate; !g.Equal(w) {
 					t.Errorf("fixStartAndEndDates() %s.ActualEndDate == %v, want %v", tr.Id, g, w)
 				}
+				if g, w := tr.StartSource+"/"+tr.EndSource, wa.StartSource+"/"+wa.EndSource; g != w {
+					t.Errorf("fixStartAndEndDates() %s sources == %s, want %s", tr.Id, g, w)
+				}
+				if g, w := tr.DestinationTimezone, wa.DestinationTimezone; g != w {
+					t.Errorf("fixStartAndEndDates() %s.DestinationTimezone == %q, want %q", tr.Id, g, w)
+				}
 			}
 		}
 	}
 }
+
+func TestDestination(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2016, 8, day, hour, 0, 0, 0, time.UTC) }
+	fly := func(from, to, tz string, departs, arrives time.Time) flight {
+		return flight{
+			Segment: Segment{StartAirportCode: from, EndAirportCode: to, EndDateTime: DateTime{Timezone: tz}},
+			departs: departs,
+			arrives: arrives,
+		}
+	}
+
+	cases := []struct {
+		name string
+		fs   []flight
+		want string
+	}{
+		{"none", nil, ""},
+		{"direct", []flight{
+			fly("DUB", "LIS", "Europe/Lisbon", at(20, 7), at(20, 10)),
+			fly("LIS", "DUB", "Europe/Dublin", at(25, 18), at(25, 20)),
+		}, "Europe/Lisbon"},
+		{"connection", []flight{
+			fly("LHR", "LIS", "Europe/Lisbon", at(20, 10), at(20, 13)),
+			fly("DUB", "LHR", "Europe/London", at(20, 7), at(20, 8)),
+		}, "Europe/Lisbon"},
+		{"overnight connection", []flight{
+			fly("DUB", "JFK", "America/New_York", at(20, 12), at(20, 19)),
+			fly("JFK", "SFO", "America/Los_Angeles", at(21, 8), at(21, 14)),
+		}, "America/Los_Angeles"},
+		{"stopover", []flight{
+			fly("DUB", "LHR", "Europe/London", at(20, 7), at(20, 8)),
+			fly("LHR", "JFK", "America/New_York", at(23, 10), at(23, 18)),
+		}, "Europe/London"},
+		{"day trip", []flight{
+			fly("DUB", "LHR", "Europe/London", at(20, 7), at(20, 8)),
+			fly("LHR", "DUB", "Europe/Dublin", at(20, 18), at(20, 19)),
+		}, "Europe/London"},
+		{"separate journey", []flight{
+			fly("DUB", "LHR", "Europe/London", at(20, 7), at(20, 8)),
+			fly("LGW", "LIS", "Europe/Lisbon", at(20, 12), at(20, 15)),
+		}, "Europe/London"},
+		{"unknown airports", []flight{
+			fly("", "", "Europe/London", at(20, 7), at(20, 8)),
+			fly("", "", "Europe/Lisbon", at(20, 10), at(20, 13)),
+		}, "Europe/Lisbon"},
+	}
+
+	for _, c := range cases {
+		if got := destination(c.fs); got != c.want {
+			t.Errorf("destination(%s) == %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	// A single trip and flight, as TripIt returns them: not in lists.
+	data := []byte(`{
+		"Trip": {"id": "T0", "display_name": "Lisbon", "start_date": "2016-08-16", "end_date": "2016-08-18"},
+		"AirObject": {"id": "A0", "trip_id": "T0", "Segment": {
+			"StartDateTime": {"date": "2016-08-16", "time": "10:00:00", "timezone": "Europe/Lisbon", "utc_offset": "+01:00"},
+			"EndDateTime": {"date": "2016-08-16", "time": "12:30:00", "timezone": "Europe/Lisbon", "utc_offset": "+01:00"}
+		}}
+	}`)
+
+	tr, err := ParseResponse(data)
+	if err != nil {
+		t.Fatalf("ParseResponse() == error (%v), want no error", err)
+	}
+	if len(tr.Trip) != 1 || tr.Trip[0].DisplayName != "Lisbon" {
+		t.Fatalf("ParseResponse() == %+v, want trip Lisbon", tr.Trip)
+	}
+
+	// The trip's dates are corrected as for List, though this variant
+	// loses the flight (see unmarshalResponse).
+	if got, want := tr.Trip[0].ActualStartDate, time.Date(2016, 8, 16, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ParseResponse() ActualStartDate == %v, want %v", got, want)
+	}
+}
+
+func TestAttachLodging(t *testing.T) {
+	data := []byte(`{
+		"Trip": [{"id": "T0", "start_date": "2016-08-16", "end_date": "2016-08-20"}, {"id": "T1", "start_date": "2016-09-01", "end_date": "2016-09-02"}],
+		"LodgingObject": [{
+			"id": "L1", "trip_id": "T0", "supplier_name": "Hotel Porto", "supplier_conf_num": "P123",
+			"StartDateTime": {"date": "2016-08-18", "timezone": "Europe/Lisbon"},
+			"EndDateTime": {"date": "2016-08-20", "time": "10:00:00", "timezone": "Europe/Lisbon"},
+			"Address": {"address": "1 Rua Porto", "city": "Porto", "latitude": "41.15"}
+		}, {
+			"id": "L0", "trip_id": "T0", "supplier_name": "Hotel Lisboa",
+			"StartDateTime": {"date": "2016-08-16", "time": "14:00:00", "timezone": "Europe/Lisbon"},
+			"EndDateTime": {"date": "2016-08-18", "timezone": "Europe/Lisbon"}
+		}]
+	}`)
+
+	tr, err := ParseResponse(data)
+	if err != nil {
+		t.Fatalf("ParseResponse() == error (%v), want no error", err)
+	}
+	if len(tr.Trip) != 2 {
+		t.Fatalf("ParseResponse() == %+v, want 2 trips", tr.Trip)
+	}
+
+	var ids []string
+	for _, l := range tr.Trip[0].Lodging {
+		ids = append(ids, l.Id)
+	}
+	if want := []string{"L0", "L1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ParseResponse() T0 lodging == %v, want %v", ids, want)
+	}
+	if got := tr.Trip[1].Lodging; len(got) != 0 {
+		t.Errorf("ParseResponse() T1 lodging == %v, want none", got)
+	}
+
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	porto := tr.Trip[0].Lodging[1]
+	if porto.Address.City != "Porto" || porto.SupplierConfNum != "P123" {
+		t.Errorf("ParseResponse() lodging == %+v, want Hotel Porto (P123) in Porto", porto)
+	}
+	cases := []struct {
+		fn   func() (time.Time, error)
+		want time.Time
+	}{
+		{porto.CheckIn, time.Date(2016, 8, 18, 15, 0, 0, 0, lisbon)},
+		{porto.CheckOut, time.Date(2016, 8, 20, 10, 0, 0, 0, lisbon)},
+		{tr.Trip[0].Lodging[0].CheckOut, time.Date(2016, 8, 18, 11, 0, 0, 0, lisbon)},
+	}
+	for i, c := range cases {
+		if got, err := c.fn(); err != nil || !got.Equal(c.want) {
+			t.Errorf("%d: == %v (%v), want %v", i, got, err, c.want)
+		}
+	}
+
+	// A single lodging, as TripIt returns it: not in a list.
+	tr, err = ParseResponse([]byte(`{"Trip": [{"id": "T0", "start_date": "2016-08-16", "end_date": "2016-08-20"}],
+		"LodgingObject": {"id": "L0", "trip_id": "T0", "StartDateTime": {"date": "2016-08-16"}}}`))
+	if err != nil || len(tr.Trip[0].Lodging) != 1 {
+		t.Errorf("ParseResponse() of a single lodging == %+v (%v), want it attached to T0", tr, err)
+	}
+}
@@ -1,7 +1,8 @@
 package tripit
 
-var (
-	// https://www.tripit.com/developer to register for an API key.
-	ConsumerKey    = ""
-	ConsumerSecret = ""
-)
+// Keys identify the application to TripIt. Register for them at
+// https://www.tripit.com/developer.
+type Keys struct {
+	ConsumerKey    string
+	ConsumerSecret string
+}
@@ -1,14 +1,21 @@
 package tripit
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+
 	"github.com/mrjones/oauth"
-	"log"
+	"github.com/seanrees/tripist/internal/loopback"
 )
 
-func buildConsumer() *oauth.Consumer {
+// manualCallbackURL displays the callback's parameters so they can be
+// copied by hand (see web/params.html).
+const manualCallbackURL = "https://freyr.erifax.org/tripist/"
+
+func buildConsumer(k Keys) *oauth.Consumer {
 	c := oauth.NewConsumer(
-		ConsumerKey, ConsumerSecret,
+		k.ConsumerKey, k.ConsumerSecret,
 		oauth.ServiceProvider{
 			RequestTokenUrl:   "https://api.tripit.com/oauth/request_token",
 			AuthorizeTokenUrl: "https://www.tripit.com/oauth/authorize",
@@ -16,40 +23,73 @@ func buildConsumer() *oauth.Consumer {
 		})
 
 	// Required by TripIt.
-	c.AdditionalAuthorizationUrlParams["oauth_callback"] = "https://freyr.erifax.org/tripist/"
+	c.AdditionalAuthorizationUrlParams["oauth_callback"] = manualCallbackURL
 	return c
 }
 
-func Authorize() *oauth.AccessToken {
-	c := buildConsumer()
+// AuthOptions control how Authorize obtains the user's consent.
+type AuthOptions struct {
+	// Port for the local callback server; 0 picks any free port.
+	Port int
+
+	// Manual skips the callback server: the user copies the token from the
+	// hosted parameters page instead.
+	Manual bool
+}
+
+// Authorize runs the OAuth flow for the application identified by k and
+// returns the user's access token. By default a local server receives
+// TripIt's callback; if that cannot start, or opts.Manual is set, the user
+// pastes the token instead.
+func Authorize(ctx context.Context, k Keys, opts AuthOptions) (*oauth.AccessToken, error) {
+	c := buildConsumer(k)
 	//c.Debug(true)
 
-	requestToken, url, err := c.GetRequestTokenAndUrl("")
-	if err != nil {
-		log.Fatal(err)
-	}
+	var srv *loopback.Server
+	if !opts.Manual {
+		var err error
+		srv, err = loopback.Listen(opts.Port)
+		if err != nil {
+			fmt.Printf("Could not start local callback server (%v), falling back to manual authorization.\n", err)
+		} else {
+			defer srv.Close()
 
-	fmt.Println("1. Login to TripIt in your browser.")
-	fmt.Println("2. After login, browse to: " + url)
-	fmt.Println("3. Grant access and copy the 'oauth_token' parameter displayed.")
-	fmt.Print("\nEnter oauth_token: ")
-	verifyCode := ""
-	fmt.Scanln(&verifyCode)
+			// OAuth 1.0 has no state parameter, so we carry our own on
+			// the callback.
+			cb := srv.URL() + "?" + url.Values{loopback.StateParam: {srv.State()}}.Encode()
+			c.AdditionalAuthorizationUrlParams["oauth_callback"] = cb
+		}
+	}
 
-	at, err := c.AuthorizeToken(requestToken, verifyCode)
+	requestToken, authURL, err := c.GetRequestTokenAndUrl("")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	fmt.Println("Access token: " + at.Token)
-	fmt.Println("Secret:       " + at.Secret)
-	fmt.Println("Additional data:")
-	for k, v := range at.AdditionalData {
-		fmt.Printf("   %s = %s", k, v)
-	}
-	if len(at.AdditionalData) == 0 {
-		fmt.Println("  none")
+	verifyCode := ""
+	if srv != nil {
+		fmt.Println("Login to TripIt and grant access. Browse to (if your browser does not open): " + authURL)
+		loopback.OpenBrowser(authURL)
+		fmt.Println("Waiting for authorization...")
+
+		q, err := srv.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if q.Get("oauth_token") != requestToken.Token {
+			return nil, fmt.Errorf("callback for a different request token, please restart authorization")
+		}
+		verifyCode = q.Get("oauth_token")
+	} else {
+		fmt.Println("1. Login to TripIt in your browser.")
+		fmt.Println("2. After login, browse to: " + authURL)
+		fmt.Println("3. Grant access and copy the 'oauth_token' parameter displayed.")
+		fmt.Print("\nEnter oauth_token: ")
+		fmt.Scanln(&verifyCode)
+		if verifyCode != requestToken.Token {
+			return nil, fmt.Errorf("oauth_token does not match this request, please restart authorization")
+		}
 	}
 
-	return at
+	return c.AuthorizeToken(requestToken, verifyCode)
 }
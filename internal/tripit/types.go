@@ -1,6 +1,8 @@
 package tripit
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -16,8 +18,9 @@ type TripitResponse struct {
 	// not a list.
 	Trip []Trip
 
-	AirObject []AirObject
-	// Other data includes: LodgingObject, WeatherObject, and Profile.
+	AirObject     []AirObject
+	LodgingObject Lodgings
+	// Other data includes: WeatherObject, and Profile.
 }
 
 // Tripit brokenness: it will return a single Trip object (instead of
@@ -46,8 +49,32 @@ type Trip struct {
 	// Set by fixStartAndEndDates.
 	ActualStartDate time.Time `json:"-"`
 	ActualEndDate   time.Time `json:"-"`
+
+	// StartSource and EndSource say where ActualStartDate and ActualEndDate
+	// came from: FromFlights or FromTrip. Set by fixStartAndEndDates.
+	StartSource string `json:"-"`
+	EndSource   string `json:"-"`
+
+	// DestinationTimezone is where the trip's outbound flight (and any
+	// connections) lands, e.g; Europe/Lisbon; empty if it has no flights.
+	// Set by fixStartAndEndDates.
+	DestinationTimezone string `json:"-"`
+
+	// Lodging is where the trip stays, in order of check-in. Set by
+	// attachLodging.
+	Lodging []LodgingObject `json:"-"`
 }
 
+// Sources of a trip's actual dates.
+const (
+	// FromFlights dates are the earliest departure or latest arrival of the
+	// trip's flights.
+	FromFlights = "flights"
+
+	// FromTrip dates are the trip's own, at midnight UTC.
+	FromTrip = "trip"
+)
+
 func (t *Trip) Start() (time.Time, error) {
 	return time.Parse(time.RFC3339, t.StartDate+"T00:00:00Z")
 }
@@ -163,3 +190,63 @@ func (dt *DateTime) Parse() (time.Time, error) {
 	}
 	return time.ParseInLocation("2006-01-02 15:04:05", dt.Date+" "+dt.Time, loc)
 }
+
+// parseOr parses dt, at the time of day def if dt has no time.
+func (dt DateTime) parseOr(def string) (time.Time, error) {
+	if len(dt.Time) == 0 {
+		dt.Time = def
+	}
+	return dt.Parse()
+}
+
+// Default times of day for lodging TripIt has no check-in or check-out time
+// for.
+const (
+	DefaultCheckIn  = "15:00:00"
+	DefaultCheckOut = "11:00:00"
+)
+
+// A LodgingObject is a stay at a hotel, or the like, during a trip.
+type LodgingObject struct {
+	Id              string `json:"id"`
+	TripId          string `json:"trip_id"`
+	DisplayName     string `json:"display_name"`
+	SupplierName    string `json:"supplier_name"`
+	SupplierConfNum string `json:"supplier_conf_num"`
+
+	// StartDateTime is check-in, and EndDateTime check-out, in the
+	// lodging's timezone.
+	StartDateTime DateTime
+	EndDateTime   DateTime
+
+	Address Address
+}
+
+// CheckIn returns when the stay starts, at DefaultCheckIn if TripIt has no
+// time for it.
+func (l *LodgingObject) CheckIn() (time.Time, error) {
+	return l.StartDateTime.parseOr(DefaultCheckIn)
+}
+
+// CheckOut returns when the stay ends, at DefaultCheckOut if TripIt has no
+// time for it.
+func (l *LodgingObject) CheckOut() (time.Time, error) {
+	return l.EndDateTime.parseOr(DefaultCheckOut)
+}
+
+// Lodgings are the LodgingObjects of a response. Like AirObjects, TripIt
+// returns a single LodgingObject, rather than a list of one, if there is
+// only one.
+type Lodgings []LodgingObject
+
+func (l *Lodgings) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var o LodgingObject
+		if err := json.Unmarshal(data, &o); err != nil {
+			return err
+		}
+		*l = Lodgings{o}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]LodgingObject)(l))
+}
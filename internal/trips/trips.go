@@ -0,0 +1,167 @@
+// Package trips describes the trips tripist sees and the projects it keeps
+// for them, so people can check what a sync would act on.
+package trips
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+// Formats for Write.
+const (
+	Table = "table"
+	JSON  = "json"
+)
+
+// An Entry is a trip and its project.
+type Entry struct {
+	Trip tripit.Trip
+
+	// Project is the trip's project, or nil if it has none (or it was not
+	// looked up).
+	Project *Project
+}
+
+// A Project summarises a trip's project. Completed tasks are not counted:
+// Todoist's sync reads leave them out.
+type Project struct {
+	Name string
+	Open int
+}
+
+// NewProject summarises p.
+func NewProject(p tasks.Project) *Project {
+	ret := &Project{Name: p.Name}
+	for _, t := range p.Tasks {
+		if !t.Completed {
+			ret.Open++
+		}
+	}
+	return ret
+}
+
+// A Filter chooses trips. The zero Filter matches every trip.
+type Filter struct {
+	// Name, if set, must match the trip's name.
+	Name *regexp.Regexp
+
+	// Purpose, if set, is the trip's purpose type code, in any case.
+	Purpose string
+
+	// From and To, if set, bound the trip's actual dates: trips that end
+	// before From or start after To do not match.
+	From, To time.Time
+}
+
+// Match returns whether t matches f.
+func (f Filter) Match(t tripit.Trip) bool {
+	if f.Name != nil && !f.Name.MatchString(t.DisplayName) {
+		return false
+	}
+	if len(f.Purpose) > 0 && !strings.EqualFold(f.Purpose, t.TripPurposes.PurposeTypeCode) {
+		return false
+	}
+	if !f.From.IsZero() && t.ActualEndDate.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && t.ActualStartDate.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the trips in ts that match f, in order.
+func (f Filter) Apply(ts []tripit.Trip) []tripit.Trip {
+	var ret []tripit.Trip
+	for _, t := range ts {
+		if f.Match(t) {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// Write writes es to w in format, with times in loc.
+func Write(w io.Writer, format string, es []Entry, loc *time.Location) error {
+	switch format {
+	case Table:
+		return writeTable(w, es, loc)
+	case JSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(toJSON(es, loc))
+	}
+	return fmt.Errorf("unknown format %q (want %s or %s)", format, Table, JSON)
+}
+
+func writeTable(out io.Writer, es []Entry, loc *time.Location) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTRIPIT DATES\tSTART\tEND\tPURPOSE\tLOCATION\tPROJECT\tOPEN")
+	for _, e := range es {
+		t := e.Trip
+		project, open := "-", ""
+		if e.Project != nil {
+			project = e.Project.Name
+			open = fmt.Sprint(e.Project.Open)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s..%s\t%s (%s)\t%s (%s)\t%s\t%s\t%s\t%s\n",
+			t.Id, t.DisplayName, t.StartDate, t.EndDate,
+			t.ActualStartDate.In(loc).Format(tasks.DueFormat), t.StartSource,
+			t.ActualEndDate.In(loc).Format(tasks.DueFormat), t.EndSource,
+			t.TripPurposes.PurposeTypeCode, t.PrimaryLocation, project, open)
+	}
+	return w.Flush()
+}
+
+type jsonEntry struct {
+	Id                  string       `json:"id"`
+	Name                string       `json:"name"`
+	TripitStart         string       `json:"tripit_start"`
+	TripitEnd           string       `json:"tripit_end"`
+	Start               time.Time    `json:"start"`
+	StartSource         string       `json:"start_source"`
+	End                 time.Time    `json:"end"`
+	EndSource           string       `json:"end_source"`
+	Purpose             string       `json:"purpose,omitempty"`
+	Location            string       `json:"location,omitempty"`
+	DestinationTimezone string       `json:"destination_timezone,omitempty"`
+	Project             *jsonProject `json:"project"`
+}
+
+type jsonProject struct {
+	Name string `json:"name"`
+	Open int    `json:"open"`
+}
+
+func toJSON(es []Entry, loc *time.Location) []jsonEntry {
+	ret := []jsonEntry{}
+	for _, e := range es {
+		t := e.Trip
+		je := jsonEntry{
+			Id:                  t.Id,
+			Name:                t.DisplayName,
+			TripitStart:         t.StartDate,
+			TripitEnd:           t.EndDate,
+			Start:               t.ActualStartDate.In(loc),
+			StartSource:         t.StartSource,
+			End:                 t.ActualEndDate.In(loc),
+			EndSource:           t.EndSource,
+			Purpose:             t.TripPurposes.PurposeTypeCode,
+			Location:            t.PrimaryLocation,
+			DestinationTimezone: t.DestinationTimezone,
+		}
+		if p := e.Project; p != nil {
+			je.Project = &jsonProject{Name: p.Name, Open: p.Open}
+		}
+		ret = append(ret, je)
+	}
+	return ret
+}
@@ -0,0 +1,129 @@
+package trips
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seanrees/tripist/internal/tasks"
+	"github.com/seanrees/tripist/internal/tripit"
+)
+
+var testTrips = []tripit.Trip{{
+	Id:              "T0",
+	DisplayName:     "Lisbon",
+	StartDate:       "2016-07-15",
+	EndDate:         "2016-07-20",
+	PrimaryLocation: "Lisbon, Portugal",
+	TripPurposes:    tripit.TripPurpose{PurposeTypeCode: "L"},
+	ActualStartDate: time.Date(2016, 07, 15, 9, 00, 00, 00, time.UTC),
+	ActualEndDate:   time.Date(2016, 07, 20, 18, 00, 00, 00, time.UTC),
+	StartSource:     tripit.FromFlights,
+	EndSource:       tripit.FromFlights,
+}, {
+	Id:              "T1",
+	DisplayName:     "Berlin conference",
+	StartDate:       "2016-08-01",
+	EndDate:         "2016-08-03",
+	TripPurposes:    tripit.TripPurpose{PurposeTypeCode: "B"},
+	ActualStartDate: time.Date(2016, 8, 1, 00, 00, 00, 00, time.UTC),
+	ActualEndDate:   time.Date(2016, 8, 3, 00, 00, 00, 00, time.UTC),
+	StartSource:     tripit.FromTrip,
+	EndSource:       tripit.FromTrip,
+}}
+
+func TestFilter(t *testing.T) {
+	cases := []struct {
+		f    Filter
+		want []string
+	}{
+		{Filter{}, []string{"T0", "T1"}},
+		{Filter{Name: regexp.MustCompile("(?i)^berlin")}, []string{"T1"}},
+		{Filter{Purpose: "l"}, []string{"T0"}},
+		{Filter{From: time.Date(2016, 07, 20, 18, 00, 00, 00, time.UTC)}, []string{"T0", "T1"}},
+		{Filter{From: time.Date(2016, 07, 21, 00, 00, 00, 00, time.UTC)}, []string{"T1"}},
+		{Filter{To: time.Date(2016, 07, 31, 00, 00, 00, 00, time.UTC)}, []string{"T0"}},
+		{Filter{Name: regexp.MustCompile("Lisbon"), Purpose: "B"}, nil},
+	}
+
+	for _, c := range cases {
+		var got []string
+		for _, tr := range c.f.Apply(testTrips) {
+			got = append(got, tr.Id)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v.Apply() == %v, want %v", c.f, got, c.want)
+		}
+	}
+}
+
+func TestNewProject(t *testing.T) {
+	p := tasks.Project{Name: "Trip: Lisbon", Tasks: []tasks.Task{
+		{Content: "Pack"},
+		{Content: "Visa", Completed: true},
+		{Content: "Unpack"},
+	}}
+	want := &Project{Name: "Trip: Lisbon", Open: 2}
+	if got := NewProject(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewProject() == %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	es := []Entry{
+		{Trip: testTrips[0], Project: &Project{Name: "Trip: Lisbon", Open: 2}},
+		{Trip: testTrips[1]},
+	}
+	var b bytes.Buffer
+	if err := Write(&b, Table, es, time.UTC); err != nil {
+		t.Fatalf("Write() == %v, want nil", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Write(Table) == %q, want a header and 2 rows", b.String())
+	}
+	for i, want := range [][]string{
+		{"T0", "Lisbon", "2016-07-15..2016-07-20", "Fri 15 Jul 2016 09:00 (flights)", "L", "Trip: Lisbon", "2"},
+		{"T1", "2016-08-01..2016-08-03", "Mon 1 Aug 2016 00:00 (trip)", "B", " -"},
+	} {
+		for _, w := range want {
+			if !strings.Contains(lines[i+1], w) {
+				t.Errorf("Write(Table) row %d == %q, want it to contain %q", i, lines[i+1], w)
+			}
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	es := []Entry{
+		{Trip: testTrips[0], Project: &Project{Name: "Trip: Lisbon", Open: 2}},
+		{Trip: testTrips[1]},
+	}
+	var b bytes.Buffer
+	if err := Write(&b, JSON, es, time.UTC); err != nil {
+		t.Fatalf("Write() == %v, want nil", err)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s) == %v, want nil", b.String(), err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Write(JSON) == %s, want 2 trips", b.String())
+	}
+	if g, w := got[0]["start_source"], tripit.FromFlights; g != w {
+		t.Errorf("Write(JSON) [0].start_source == %v, want %v", g, w)
+	}
+	want := map[string]interface{}{"name": "Trip: Lisbon", "open": 2.0}
+	if g := got[0]["project"]; !reflect.DeepEqual(g, want) {
+		t.Errorf("Write(JSON) [0].project == %v, want %v", g, want)
+	}
+	if g := got[1]["project"]; g != nil {
+		t.Errorf("Write(JSON) [1].project == %v, want null", g)
+	}
+}